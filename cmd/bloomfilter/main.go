@@ -0,0 +1,73 @@
+// Command bloomfilter builds the breached password filter read by the
+// server from a password list, e.g. the SHA-1 list of Have I Been Pwned:
+//
+//	go run ./cmd/bloomfilter -in pwned-passwords-sha1.txt -out breached.bloom
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/mauzec/user-api/internal/util"
+)
+
+func main() {
+	in := flag.String("in", "", "password list, one password or SHA-1 digest per line")
+	out := flag.String("out", "breached.bloom", "where to write the filter")
+	falsePositiveRate := flag.Float64("fp", 0.001, "false positive rate of the filter")
+	flag.Parse()
+
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	n, err := countLines(*in)
+	if err != nil {
+		log.Fatal("unable to read list:", err)
+	}
+
+	list, err := os.Open(*in)
+	if err != nil {
+		log.Fatal("unable to read list:", err)
+	}
+	defer list.Close()
+
+	filter := util.NewBloomFilter(n, *falsePositiveRate)
+	if err = filter.AddList(list); err != nil {
+		log.Fatal("unable to read list:", err)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Fatal("unable to create filter:", err)
+	}
+	w := bufio.NewWriter(file)
+	if _, err = filter.WriteTo(w); err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatal("unable to write filter:", err)
+	}
+	log.Printf("wrote a filter of %d passwords to %s", n, *out)
+}
+
+func countLines(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		n++
+	}
+	return n, scanner.Err()
+}
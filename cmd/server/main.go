@@ -3,12 +3,18 @@ package main
 import (
 	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	db "github.com/mauzec/user-api/db/sqlc"
 	"github.com/mauzec/user-api/internal/api"
 	"github.com/mauzec/user-api/internal/config"
+	"github.com/mauzec/user-api/internal/notify"
 	"github.com/mauzec/user-api/internal/token"
+	"github.com/mauzec/user-api/internal/util"
 )
 
 func main() {
@@ -26,7 +32,16 @@ func main() {
 	var tokenMaker token.Maker
 	switch config.TokenType {
 	case "PasetoS":
-		tokenMaker, err = token.NewPasetoSMaker(config.TokenSymmetricKey)
+		var keyring *token.Keyring
+		keyring, err = newKeyring(config)
+		if err == nil {
+			tokenMaker = token.NewPasetoSMakerWithKeyring(keyring)
+			go reloadKeyringOnSignal(keyring)
+		}
+	case "PasetoP":
+		tokenMaker, err = token.NewPasetoPMakerFromFiles(config.TokenPrivateKeyFile, config.TokenPublicKeyFile)
+	case "JWT":
+		tokenMaker, err = token.NewJWTMaker(config.TokenSymmetricKey)
 	default:
 		log.Fatal("given unsupported token type")
 	}
@@ -34,14 +49,91 @@ func main() {
 		log.Fatal("something go wrong when creating token maker:", err)
 	}
 
+	var notifier notify.Notifier
+	switch config.Notifier {
+	case "", "log":
+		notifier = notify.NewLogNotifier()
+	case "file":
+		notifier = notify.NewFileNotifier(config.NotifierFile)
+	case "smtp":
+		notifier = notify.NewSMTPNotifier(
+			config.SMTPHost,
+			config.SMTPPort,
+			config.SMTPUsername,
+			config.SMTPPassword,
+			config.SMTPFrom,
+		)
+	default:
+		log.Fatal("given unsupported notifier")
+	}
+
+	var smsSender notify.SMSSender
+	switch config.SMSSender {
+	case "", "log":
+		smsSender = notify.NewLogSMSSender()
+	case "file":
+		smsSender = notify.NewFileSMSSender(config.SMSSenderFile)
+	default:
+		log.Fatal("given unsupported sms sender")
+	}
+
+	rateLimits, err := newRateLimits(config)
+	if err != nil {
+		log.Fatal("invalid rate limit:", err)
+	}
+
+	passwordPolicy, err := newPasswordPolicy(config)
+	if err != nil {
+		log.Fatal("unable to load breached passwords:", err)
+	}
+
 	store := db.NewStore(conn)
 	server, err := api.NewServer(store, tokenMaker, api.TokenParams{
-		AccessTokenDuration: config.AccessTokenDuration,
-	})
+		AccessTokenDuration:        config.AccessTokenDuration,
+		RefreshTokenDuration:       config.RefreshTokenDuration,
+		PasswordResetTokenDuration: config.PasswordResetTokenDuration,
+		EmailVerificationDuration:  config.EmailVerificationDuration,
+		PhoneVerificationDuration:  config.PhoneVerificationDuration,
+		MfaTokenDuration:           config.MfaTokenDuration,
+	},
+		api.WithNotifier(notifier),
+		api.WithSMSSender(smsSender),
+		api.WithCodeHashKey(config.CodeHashKey),
+		api.WithUnverifiedLogin(config.AllowUnverifiedLogin),
+		api.WithPrivateSignup(config.PrivateSignup),
+		api.WithVerificationLimits(api.VerificationLimits{
+			ResendInterval: config.VerificationResendInterval,
+			DailyLimit:     config.VerificationDailyLimit,
+		}),
+		api.WithDeletionGracePeriod(config.UserDeletionGracePeriod),
+		api.WithPasswordHasher(util.NewArgon2idHasher(util.Argon2idParams{
+			Memory:      config.PasswordHashMemory,
+			Iterations:  config.PasswordHashIterations,
+			Parallelism: config.PasswordHashParallelism,
+		})),
+		api.WithPasswordPolicy(passwordPolicy),
+		api.WithLoginThrottle(api.LoginThrottleParams{
+			FailureWindow:      config.LoginFailureWindow,
+			BackoffThreshold:   config.LoginBackoffThreshold,
+			IPBackoffThreshold: config.LoginIPBackoffThreshold,
+			BackoffBase:        config.LoginBackoffBase,
+			BackoffMax:         config.LoginBackoffMax,
+			LockoutThreshold:   config.LoginLockoutThreshold,
+			LockoutDuration:    config.LoginLockoutDuration,
+		}),
+		api.WithRateLimits(rateLimits),
+	)
 	if err != nil {
 		log.Fatal("server creating err:", err)
 	}
 
+	if config.UserPurgeInterval > 0 {
+		go purgeDeletedUsers(server, config.UserPurgeInterval)
+	}
+	if config.LoginFailureWindow > 0 {
+		go deleteStaleLoginFailures(server, config.LoginFailureWindow)
+	}
+
 	if config.TLSCertFile != "" && config.TLSKeyFile != "" {
 		err = server.RunTLS(config.ServerAddr, config.TLSCertFile, config.TLSKeyFile)
 	} else {
@@ -51,3 +143,121 @@ func main() {
 		log.Fatal("catch error when starting server")
 	}
 }
+
+// newKeyring builds the PasetoS keyring from config. Without
+// TOKEN_SYMMETRIC_KEYS the single TOKEN_SYMMETRIC_KEY is used under
+// the legacy key ID, so tokens issued before rotation stay valid.
+func newKeyring(config config.Config) (*token.Keyring, error) {
+	activeID, keys, err := keyringKeys(config)
+	if err != nil {
+		return nil, err
+	}
+	return token.NewKeyring(activeID, keys)
+}
+
+func keyringKeys(config config.Config) (string, map[string][]byte, error) {
+	if config.TokenSymmetricKeys == "" {
+		return token.LegacyKeyID, map[string][]byte{
+			token.LegacyKeyID: []byte(config.TokenSymmetricKey),
+		}, nil
+	}
+
+	keys, err := token.ParseKeys(config.TokenSymmetricKeys)
+	if err != nil {
+		return "", nil, err
+	}
+	return config.TokenActiveKeyID, keys, nil
+}
+
+func newRateLimits(config config.Config) (api.RateLimits, error) {
+	var limits api.RateLimits
+	for _, l := range []struct {
+		limit *api.RateLimit
+		value string
+	}{
+		{&limits.Signup, config.RateLimitSignup},
+		{&limits.Login, config.RateLimitLogin},
+		{&limits.Read, config.RateLimitRead},
+		{&limits.Write, config.RateLimitWrite},
+		{&limits.Admin, config.RateLimitAdmin},
+	} {
+		limit, err := api.ParseRateLimit(l.value)
+		if err != nil {
+			return api.RateLimits{}, err
+		}
+		*l.limit = limit
+	}
+	return limits, nil
+}
+
+func newPasswordPolicy(config config.Config) (util.PasswordPolicy, error) {
+	policy := util.PasswordPolicy{
+		MinLength:     config.PasswordMinLength,
+		MaxLength:     config.PasswordMaxLength,
+		RequireLower:  config.PasswordRequireLower,
+		RequireUpper:  config.PasswordRequireUpper,
+		RequireDigit:  config.PasswordRequireDigit,
+		RequireSymbol: config.PasswordRequireSymbol,
+	}
+	if config.PasswordBreachedFile != "" {
+		breached, err := util.LoadBreachedPasswords(config.PasswordBreachedFile)
+		if err != nil {
+			return util.PasswordPolicy{}, err
+		}
+		policy.Breached = breached
+	}
+	return policy, nil
+}
+
+func reloadKeyringOnSignal(keyring *token.Keyring) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+
+	for range sigs {
+		newConfig, err := config.LoadConfig("app", "env", "./config")
+		if err != nil {
+			log.Println("unable to reload config:", err)
+			continue
+		}
+		activeID, keys, err := keyringKeys(newConfig)
+		if err == nil {
+			err = keyring.Replace(activeID, keys)
+		}
+		if err != nil {
+			log.Println("unable to reload token keyring:", err)
+			continue
+		}
+		log.Printf("token keyring reloaded, active key: %s", activeID)
+	}
+}
+
+// purgeDeletedUsers removes users whose deletion grace period is over. Several
+// instances may run it at once, a user is purged by only one of them.
+func purgeDeletedUsers(server *api.Server, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purged, err := server.PurgeDeletedUsers(context.Background())
+		if err != nil {
+			log.Println("unable to purge deleted users:", err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("purged %d deleted users", purged)
+		}
+	}
+}
+
+// deleteStaleLoginFailures forgets failed logins which no longer count,
+// once per failure window.
+func deleteStaleLoginFailures(server *api.Server, window time.Duration) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := server.DeleteStaleLoginFailures(context.Background()); err != nil {
+			log.Println("unable to delete stale login failures:", err)
+		}
+	}
+}
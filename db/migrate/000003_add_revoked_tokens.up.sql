@@ -0,0 +1,11 @@
+CREATE TABLE "revoked_tokens" (
+    "id" uuid PRIMARY KEY,
+
+    "username" varchar NOT NULL,
+
+    "expires_at" timestamptz NOT NULL,
+    "revoked_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_revoked_at_idx
+    ON "revoked_tokens" ("revoked_at");
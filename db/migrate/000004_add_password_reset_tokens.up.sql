@@ -0,0 +1,16 @@
+CREATE TABLE "password_reset_tokens" (
+    "id" bigserial PRIMARY KEY,
+
+    "user_id" bigint NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+    -- only a sha256 of the token is stored
+    "token_hash" varchar NOT NULL,
+
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS password_reset_tokens_token_hash_unique
+    ON "password_reset_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS password_reset_tokens_user_id_idx
+    ON "password_reset_tokens" ("user_id");
@@ -0,0 +1,21 @@
+ALTER TABLE "users" ADD COLUMN "email_verified_at" timestamptz;
+ALTER TABLE "users" ALTER COLUMN "status" SET DEFAULT 'pending_verification';
+
+CREATE TABLE "email_verifications" (
+    "id" bigserial PRIMARY KEY,
+
+    "user_id" bigint NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+    -- the address the code was sent to
+    "email" varchar NOT NULL,
+    -- only a sha256 of the code is stored
+    "code_hash" varchar NOT NULL,
+
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS email_verifications_code_hash_unique
+    ON "email_verifications" ("code_hash");
+CREATE INDEX IF NOT EXISTS email_verifications_user_id_idx
+    ON "email_verifications" ("user_id");
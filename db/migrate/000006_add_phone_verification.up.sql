@@ -0,0 +1,20 @@
+ALTER TABLE "users" ADD COLUMN "phone_verified_at" timestamptz;
+
+CREATE TABLE "phone_verifications" (
+    "id" bigserial PRIMARY KEY,
+
+    "user_id" bigint NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+    -- the number the code was sent to
+    "phone" varchar NOT NULL,
+    -- only a sha256 of the code is stored
+    "code_hash" varchar NOT NULL,
+    -- failed and successful confirmation attempts
+    "attempts" int NOT NULL DEFAULT 0,
+
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS phone_verifications_user_id_idx
+    ON "phone_verifications" ("user_id");
@@ -0,0 +1,42 @@
+CREATE TABLE "user_totps" (
+    "user_id" bigint PRIMARY KEY REFERENCES "users" ("id") ON DELETE CASCADE,
+
+    -- base32 encoded, authenticator apps need the secret itself
+    "secret" varchar NOT NULL,
+    -- 2FA is enforced only after the user proves the app has the secret
+    "confirmed_at" timestamptz,
+    -- the last used TOTP period, so a code can't be replayed
+    "last_used_step" bigint NOT NULL DEFAULT 0,
+
+    "created_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE "totp_recovery_codes" (
+    "id" bigserial PRIMARY KEY,
+
+    "user_id" bigint NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+    -- only a sha256 of the code is stored
+    "code_hash" varchar NOT NULL,
+
+    "used_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS totp_recovery_codes_user_id_idx
+    ON "totp_recovery_codes" ("user_id");
+
+CREATE TABLE "mfa_challenges" (
+    "id" bigserial PRIMARY KEY,
+
+    "user_id" bigint NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+    -- only a sha256 of the mfa_pending token is stored
+    "token_hash" varchar NOT NULL,
+    "attempts" int NOT NULL DEFAULT 0,
+
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS mfa_challenges_token_hash_unique
+    ON "mfa_challenges" ("token_hash");
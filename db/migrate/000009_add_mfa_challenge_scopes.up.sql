@@ -0,0 +1,2 @@
+-- scopes requested at login, granted once the challenge is passed
+ALTER TABLE "mfa_challenges" ADD COLUMN "scopes" varchar[] NOT NULL DEFAULT '{}';
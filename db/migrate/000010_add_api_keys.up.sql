@@ -0,0 +1,23 @@
+CREATE TABLE "api_keys" (
+    "id" uuid PRIMARY KEY,
+
+    "user_id" bigint NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+    "name" varchar NOT NULL,
+    -- the start of the key, shown to tell keys apart
+    "prefix" varchar NOT NULL,
+    -- only a sha256 of the key is stored
+    "key_hash" varchar NOT NULL,
+    -- empty means all scopes of the owner's role
+    "scopes" varchar[] NOT NULL DEFAULT '{}',
+
+    "expires_at" timestamptz,
+    "last_used_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_keys_key_hash_unique
+    ON "api_keys" ("key_hash");
+-- names of keys in use must be unique for a user
+CREATE UNIQUE INDEX IF NOT EXISTS api_keys_user_id_name_unique
+    ON "api_keys" ("user_id", "name") WHERE "revoked_at" IS NULL;
@@ -0,0 +1,11 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- trigram indexes serve both ILIKE '%...%' and word similarity searches
+CREATE INDEX IF NOT EXISTS users_full_name_trgm_idx
+    ON "users" USING gin ("full_name" gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_username_trgm_idx
+    ON "users" USING gin ("username" gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_email_trgm_idx
+    ON "users" USING gin ("email" gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_phone_trgm_idx
+    ON "users" USING gin ("phone" gin_trgm_ops);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS users_deleted_at_idx;
+
+-- without the column deleted users would come back to life
+DELETE FROM "users" WHERE "deleted_at" IS NOT NULL;
+ALTER TABLE "users" DROP COLUMN IF EXISTS "deleted_at";
@@ -0,0 +1,5 @@
+ALTER TABLE "users" ADD COLUMN "deleted_at" timestamptz;
+
+-- the purge job only looks at deleted users
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx
+    ON "users" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
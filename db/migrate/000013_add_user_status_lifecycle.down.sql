@@ -0,0 +1,5 @@
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS users_status_check;
+
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS "status_expires_at",
+    DROP COLUMN IF EXISTS "status_reason";
@@ -0,0 +1,7 @@
+ALTER TABLE "users"
+    ADD COLUMN "status_reason" varchar NOT NULL DEFAULT '',
+    ADD COLUMN "status_expires_at" timestamptz;
+
+ALTER TABLE "users" ADD CONSTRAINT users_status_check CHECK ("status" IN (
+    'pending_verification', 'active', 'suspended', 'banned', 'locked', 'deleted'
+));
@@ -0,0 +1,10 @@
+-- failed logins counted per username and per client ip,
+-- keys are "username:<name>" and "ip:<address>"
+CREATE TABLE "login_failures" (
+    "key" varchar PRIMARY KEY,
+    "failures" integer NOT NULL DEFAULT 0,
+    "last_failed_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS login_failures_last_failed_at_idx
+    ON "login_failures" ("last_failed_at");
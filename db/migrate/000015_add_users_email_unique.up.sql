@@ -0,0 +1,26 @@
+-- emails identify users at login, so they have to be unique ignoring case;
+-- users sharing an email have to be sorted out first, see "Upgrading" in
+-- README.md, the check below names them instead of failing on the index
+DO $$
+DECLARE
+    duplicates text;
+BEGIN
+    SELECT string_agg(email, ', ') INTO duplicates
+    FROM (
+        SELECT lower("email") AS email
+        FROM "users"
+        GROUP BY lower("email")
+        HAVING count(*) > 1
+        ORDER BY 1
+        LIMIT 20
+    ) AS shared;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'users share these emails ignoring case: %', duplicates
+            USING HINT = 'give each user its own email before migrating, see "Upgrading" in README.md';
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS users_email_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx
+    ON "users" (lower("email"));
@@ -0,0 +1,12 @@
+UPDATE "sessions" SET "username" = u."display_username"
+FROM "users" u WHERE "sessions"."username" = u."username";
+UPDATE "revoked_tokens" SET "username" = u."display_username"
+FROM "users" u WHERE "revoked_tokens"."username" = u."username";
+
+DROP INDEX IF EXISTS users_username_unique;
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS users_username_lowercase;
+UPDATE "users" SET "username" = "display_username";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "display_username";
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_unique
+    ON "users" (lower("username"));
@@ -0,0 +1,17 @@
+-- usernames are stored lowercase, so lookups can compare them as they are;
+-- display_username keeps the casing the user signed up with
+ALTER TABLE "users" ADD COLUMN "display_username" varchar;
+UPDATE "users" SET "display_username" = "username", "username" = lower("username");
+ALTER TABLE "users" ALTER COLUMN "display_username" SET NOT NULL;
+ALTER TABLE "users" ADD CONSTRAINT users_username_lowercase
+    CHECK ("username" = lower("username"));
+
+DROP INDEX IF EXISTS users_username_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_unique
+    ON "users" ("username");
+
+-- sessions and revoked tokens refer to users by username
+UPDATE "sessions" SET "username" = lower("username");
+UPDATE "revoked_tokens" SET "username" = lower("username");
+-- failed logins are counted again from scratch under the lowercase names
+DELETE FROM "login_failures" WHERE "key" <> lower("key");
@@ -0,0 +1,3 @@
+-- tokens issued before this are rejected, it is moved forward when all
+-- sessions of the user are revoked
+ALTER TABLE "users" ADD COLUMN "tokens_valid_after" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z';
@@ -0,0 +1,4 @@
+-- deleted users are restored to the status they had, so a deletion does
+-- not lift a lock; users deleted before keep being restored as active or
+-- pending verification
+ALTER TABLE "users" ADD COLUMN "status_before_deletion" varchar NOT NULL DEFAULT '';
@@ -0,0 +1,27 @@
+-- name: CountEmailVerificationsSince :one
+SELECT count(*) AS sent,
+    min(created_at)::timestamptz AS first_sent_at,
+    max(created_at)::timestamptz AS last_sent_at
+FROM email_verifications
+WHERE user_id = $1 AND created_at > sqlc.arg(since);
+
+-- name: CreateEmailVerification :one
+INSERT INTO email_verifications (
+    user_id,
+    email,
+    code_hash,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetEmailVerification :one
+SELECT * FROM email_verifications
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL AND expires_at > now()
+LIMIT 1;
+
+-- name: UseEmailVerification :one
+UPDATE email_verifications
+SET used_at = now()
+WHERE id = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING *;
@@ -0,0 +1,26 @@
+-- name: GetLoginFailure :one
+SELECT * FROM login_failures
+WHERE key = $1 LIMIT 1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_failures (
+    key,
+    failures,
+    last_failed_at
+) VALUES (
+    $1, 1, now()
+) ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_failures.last_failed_at < sqlc.arg(window_start) THEN 1
+        ELSE login_failures.failures + 1
+    END,
+    last_failed_at = now()
+RETURNING *;
+
+-- name: ResetLoginFailures :exec
+DELETE FROM login_failures
+WHERE key = ANY(sqlc.arg(keys)::varchar[]);
+
+-- name: DeleteStaleLoginFailures :exec
+DELETE FROM login_failures
+WHERE last_failed_at < sqlc.arg(window_start);
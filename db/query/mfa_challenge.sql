@@ -0,0 +1,26 @@
+-- name: CreateMfaChallenge :one
+INSERT INTO mfa_challenges (
+    user_id,
+    token_hash,
+    expires_at,
+    scopes
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetMfaChallenge :one
+SELECT * FROM mfa_challenges
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+LIMIT 1;
+
+-- name: IncrementMfaChallengeAttempts :one
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE id = $1 AND attempts < sqlc.arg(max_attempts)::int
+RETURNING *;
+
+-- name: UseMfaChallenge :one
+UPDATE mfa_challenges
+SET used_at = now()
+WHERE id = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING *;
@@ -0,0 +1,31 @@
+-- name: CountPasswordResetTokensSince :one
+SELECT count(*) AS sent,
+    min(created_at)::timestamptz AS first_sent_at,
+    max(created_at)::timestamptz AS last_sent_at
+FROM password_reset_tokens
+WHERE user_id = $1 AND created_at > sqlc.arg(since);
+
+-- name: CreatePasswordResetToken :one
+INSERT INTO password_reset_tokens (
+    user_id,
+    token_hash,
+    expires_at
+) VALUES (
+    $1, $2, $3
+) RETURNING *;
+
+-- name: GetPasswordResetToken :one
+SELECT * FROM password_reset_tokens
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+LIMIT 1;
+
+-- name: UsePasswordResetToken :one
+UPDATE password_reset_tokens
+SET used_at = now()
+WHERE id = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING *;
+
+-- name: UseUserPasswordResetTokens :exec
+UPDATE password_reset_tokens
+SET used_at = now()
+WHERE user_id = $1 AND used_at IS NULL;
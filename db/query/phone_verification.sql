@@ -0,0 +1,37 @@
+-- name: CountPhoneVerificationsSince :one
+SELECT count(*) AS sent,
+    min(created_at)::timestamptz AS first_sent_at,
+    max(created_at)::timestamptz AS last_sent_at
+FROM phone_verifications
+WHERE user_id = $1 AND created_at > sqlc.arg(since);
+
+-- name: CreatePhoneVerification :one
+INSERT INTO phone_verifications (
+    user_id,
+    phone,
+    code_hash,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetActivePhoneVerification :one
+SELECT * FROM phone_verifications
+WHERE user_id = $1 AND phone = $2 AND used_at IS NULL AND expires_at > now()
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: IncrementPhoneVerificationAttempts :one
+UPDATE phone_verifications v
+SET attempts = v.attempts + 1
+WHERE v.id = $1 AND (
+    SELECT coalesce(sum(attempts), 0) FROM phone_verifications
+    WHERE user_id = v.user_id AND created_at > sqlc.arg(since)
+) < sqlc.arg(max_attempts)::int
+RETURNING *;
+
+-- name: UsePhoneVerification :one
+UPDATE phone_verifications
+SET used_at = now()
+WHERE id = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING *;
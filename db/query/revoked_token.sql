@@ -0,0 +1,25 @@
+-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (
+    id,
+    username,
+    expires_at
+) VALUES (
+    $1, $2, $3
+) ON CONFLICT (id) DO NOTHING;
+
+-- name: ListRevokedTokensSince :many
+SELECT * FROM revoked_tokens
+WHERE revoked_at >= $1 AND expires_at > now()
+ORDER BY revoked_at;
+
+-- name: RevokeUserSessions :many
+WITH blocked AS (
+    UPDATE sessions
+    SET is_blocked = true
+    WHERE username = $1 AND is_blocked = false AND expires_at > now()
+    RETURNING id, username, expires_at
+)
+INSERT INTO revoked_tokens (id, username, expires_at)
+SELECT id, username, expires_at FROM blocked
+ON CONFLICT (id) DO NOTHING
+RETURNING *;
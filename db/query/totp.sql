@@ -0,0 +1,47 @@
+-- name: UpsertUserTotp :one
+INSERT INTO user_totps (
+    user_id,
+    secret
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    last_used_step = 0,
+    created_at = now()
+WHERE user_totps.confirmed_at IS NULL
+RETURNING *;
+
+-- name: GetUserTotp :one
+SELECT * FROM user_totps
+WHERE user_id = $1 LIMIT 1;
+
+-- name: ConfirmUserTotp :one
+UPDATE user_totps
+SET confirmed_at = now()
+WHERE user_id = $1 AND confirmed_at IS NULL
+RETURNING *;
+
+-- name: UseUserTotpStep :one
+UPDATE user_totps
+SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2
+RETURNING *;
+
+-- name: CreateTotpRecoveryCode :exec
+INSERT INTO totp_recovery_codes (
+    user_id,
+    code_hash
+) VALUES (
+    $1, $2
+);
+
+-- name: DeleteTotpRecoveryCodes :exec
+DELETE FROM totp_recovery_codes
+WHERE user_id = $1;
+
+-- name: UseTotpRecoveryCode :one
+UPDATE totp_recovery_codes
+SET used_at = now()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+RETURNING *;
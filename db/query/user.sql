@@ -6,22 +6,32 @@ INSERT INTO users (
     age,
     email,
     phone,
-    hashed_password
+    hashed_password,
+    display_username
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
+    $1, $2, $3, $4, $5, $6, $7, $8
 ) RETURNING *;
 
 -- name: GetUserByID :one
 SELECT * FROM users
-WHERE id = $1 LIMIT 1;
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
 
 -- name: GetUserByUsername :one
 SELECT * FROM users
-WHERE username = $1 LIMIT 1;
+WHERE username = $1 AND deleted_at IS NULL LIMIT 1;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE lower(email) = lower($1) AND deleted_at IS NULL LIMIT 1;
+
+-- name: ListUsersByPhone :many
+SELECT * FROM users
+WHERE lower(phone) = lower($1) AND deleted_at IS NULL
+LIMIT 2;
 
 -- name: GetUserByUsernameForUpdate :one
 SELECT * FROM users
-WHERE username = $1 LIMIT 1
+WHERE username = $1 AND deleted_at IS NULL LIMIT 1
 FOR NO KEY UPDATE;
 
 -- name: UpdateUser :one
@@ -29,10 +39,140 @@ UPDATE users
 SET phone = $2,
     full_name = $3,
     gender = $4,
-    email = $5
-WHERE id = $1
+    email = $5,
+    phone_verified_at = CASE WHEN phone = $2 THEN phone_verified_at END,
+    email_verified_at = CASE WHEN lower(email) = lower($5) THEN email_verified_at END
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2,
+    password_changed_at = $3
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: RevokeUserTokens :one
+UPDATE users
+SET tokens_valid_after = $2
+WHERE username = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = sqlc.arg(new_hashed_password)
+WHERE id = $1 AND hashed_password = sqlc.arg(old_hashed_password);
+
+-- name: GetUserAuthState :one
+SELECT password_changed_at, status, status_expires_at, email_verified_at, tokens_valid_after FROM users
+WHERE username = $1 AND deleted_at IS NULL LIMIT 1;
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET email_verified_at = now(),
+    status = CASE WHEN status = 'pending_verification' THEN 'active' ELSE status END
+WHERE id = $1 AND email = $2 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: VerifyUserPhone :one
+UPDATE users
+SET phone_verified_at = now()
+WHERE id = $1 AND phone = $2 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UpdateUserStatus :one
+UPDATE users
+SET status = $2,
+    status_reason = $3,
+    status_expires_at = $4
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: ListUsers :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+    AND (sqlc.narg(status)::varchar IS NULL OR CASE
+        WHEN status IN ('suspended', 'banned', 'locked') AND status_expires_at <= now()
+            THEN CASE WHEN email_verified_at IS NULL THEN 'pending_verification' ELSE 'active' END
+        ELSE status
+    END = sqlc.narg(status))
+    AND (sqlc.narg(gender)::varchar IS NULL OR gender = sqlc.narg(gender))
+    AND (sqlc.narg(min_age)::int IS NULL OR age >= sqlc.narg(min_age))
+    AND (sqlc.narg(max_age)::int IS NULL OR age <= sqlc.narg(max_age))
+    AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from))
+    AND (sqlc.narg(created_to)::timestamptz IS NULL OR created_at < sqlc.narg(created_to))
+    AND (sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (created_at, id) > (sqlc.narg(after_created_at), sqlc.narg(after_id)::bigint))
+ORDER BY created_at, id
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListUsersDesc :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+    AND (sqlc.narg(status)::varchar IS NULL OR CASE
+        WHEN status IN ('suspended', 'banned', 'locked') AND status_expires_at <= now()
+            THEN CASE WHEN email_verified_at IS NULL THEN 'pending_verification' ELSE 'active' END
+        ELSE status
+    END = sqlc.narg(status))
+    AND (sqlc.narg(gender)::varchar IS NULL OR gender = sqlc.narg(gender))
+    AND (sqlc.narg(min_age)::int IS NULL OR age >= sqlc.narg(min_age))
+    AND (sqlc.narg(max_age)::int IS NULL OR age <= sqlc.narg(max_age))
+    AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from))
+    AND (sqlc.narg(created_to)::timestamptz IS NULL OR created_at < sqlc.narg(created_to))
+    AND (sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (created_at, id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::bigint))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: SearchUsers :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+    AND (full_name ILIKE sqlc.arg(pattern)
+        OR username ILIKE sqlc.arg(pattern)
+        OR email ILIKE sqlc.arg(pattern)
+        OR phone LIKE sqlc.arg(pattern)
+        OR sqlc.arg(query)::text <% full_name
+        OR sqlc.arg(query)::text <% username
+        OR sqlc.arg(query)::text <% email)
+ORDER BY greatest(
+        word_similarity(sqlc.arg(query)::text, full_name),
+        word_similarity(sqlc.arg(query)::text, username),
+        word_similarity(sqlc.arg(query)::text, email),
+        word_similarity(sqlc.arg(query)::text, phone)
+    ) DESC, id
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: SoftDeleteUser :one
+UPDATE users
+SET deleted_at = now(),
+    status_before_deletion = status,
+    status = 'deleted'
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: GetDeletedUserByUsername :one
+SELECT * FROM users
+WHERE username = $1 AND deleted_at IS NOT NULL LIMIT 1;
+
+-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL,
+    status = CASE
+        WHEN status_before_deletion <> '' THEN status_before_deletion
+        WHEN email_verified_at IS NULL THEN 'pending_verification'
+        ELSE 'active'
+    END,
+    status_before_deletion = ''
+WHERE id = $1 AND deleted_at > sqlc.arg(deleted_after)
 RETURNING *;
 
--- name: DeleteUserByID :exec
+-- name: PurgeDeletedUsers :many
 DELETE FROM users
-WHERE id = $1;
\ No newline at end of file
+WHERE deleted_at < sqlc.arg(deleted_before)
+RETURNING username;
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func createRandomApiKey(t *testing.T, user User, expiresAt pgtype.Timestamptz) ApiKey {
+	arg := CreateApiKeyParams{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Name:      util.RandomString(8),
+		Prefix:    util.RandomString(12),
+		KeyHash:   util.HashToken(util.RandomString(32)),
+		Scopes:    []string{util.ScopeUsersRead},
+		ExpiresAt: expiresAt,
+	}
+
+	key, err := testQueries.CreateApiKey(context.Background(), arg)
+	assert.NoError(t, err)
+	assert.Equal(t, arg.ID, key.ID)
+	assert.Equal(t, arg.Name, key.Name)
+	assert.Equal(t, arg.KeyHash, key.KeyHash)
+	assert.Equal(t, arg.Scopes, key.Scopes)
+	assert.False(t, key.LastUsedAt.Valid)
+	assert.False(t, key.RevokedAt.Valid)
+	return key
+}
+
+func TestApiKey(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	key := createRandomApiKey(t, user, pgtype.Timestamptz{})
+
+	got, err := testQueries.GetApiKeyByHash(context.Background(), key.KeyHash)
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, got.ID)
+
+	err = testQueries.TouchApiKey(context.Background(), key.ID)
+	assert.NoError(t, err)
+
+	keys, err := testQueries.ListApiKeys(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.True(t, keys[0].LastUsedAt.Valid)
+
+	// a key of another user can not be revoked
+	_, err = testQueries.RevokeApiKey(context.Background(), RevokeApiKeyParams{ID: key.ID, UserID: user.ID + 1})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	revoked, err := testQueries.RevokeApiKey(context.Background(), RevokeApiKeyParams{ID: key.ID, UserID: user.ID})
+	assert.NoError(t, err)
+	assert.True(t, revoked.RevokedAt.Valid)
+
+	_, err = testQueries.GetApiKeyByHash(context.Background(), key.KeyHash)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	keys, err = testQueries.ListApiKeys(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestExpiredApiKey(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	key := createRandomApiKey(t, user, pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true})
+
+	_, err := testQueries.GetApiKeyByHash(context.Background(), key.KeyHash)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
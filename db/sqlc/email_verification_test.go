@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func createAndTestRandomUserTx(t *testing.T) (CreateUserTxResult, string) {
+	hashedPassword, err := util.HashPassword(util.RandomString(10))
+	assert.NoError(t, err)
+
+	code := util.RandomString(32)
+	args := CreateUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:       util.RandomUsername(),
+			Email:          util.RandomEmail(),
+			HashedPassword: hashedPassword,
+			FullName:       fmt.Sprintf("%s %s", util.RandomString(5), util.RandomString(5)),
+			Phone:          util.RandomPhone(),
+			Gender:         "F",
+			Age:            int32(util.RandomInt(18, 60)),
+		},
+		VerificationCodeHash:      util.HashToken(code),
+		VerificationCodeExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+
+	result, err := testStore.CreateUserTx(context.Background(), args)
+	assert.NoError(t, err)
+	assert.Equal(t, args.Username, result.User.Username)
+	assert.Equal(t, "pending_verification", result.User.Status)
+	assert.False(t, result.User.EmailVerifiedAt.Valid)
+
+	assert.Equal(t, result.User.ID, result.EmailVerification.UserID)
+	assert.Equal(t, args.Email, result.EmailVerification.Email)
+	assert.Equal(t, args.VerificationCodeHash, result.EmailVerification.CodeHash)
+
+	return result, code
+}
+
+func TestVerifyEmailTx(t *testing.T) {
+	created, code := createAndTestRandomUserTx(t)
+
+	verification, err := testQueries.GetEmailVerification(context.Background(), GetEmailVerificationParams{
+		UserID:   created.User.ID,
+		CodeHash: util.HashToken(code),
+	})
+	assert.NoError(t, err)
+
+	result, err := testStore.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailVerificationID: verification.ID,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "active", result.User.Status)
+	assert.True(t, result.User.EmailVerifiedAt.Valid)
+
+	// a used code can not be used again
+	_, err = testStore.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailVerificationID: verification.ID,
+	})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestVerifyEmailTxEmailChanged(t *testing.T) {
+	created, _ := createAndTestRandomUserTx(t)
+
+	_, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		ID:       created.User.ID,
+		Phone:    created.User.Phone,
+		FullName: created.User.FullName,
+		Gender:   created.User.Gender,
+		Email:    util.RandomEmail(),
+	})
+	assert.NoError(t, err)
+
+	_, err = testStore.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailVerificationID: created.EmailVerification.ID,
+	})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestUpdateUserEmailClearsVerification(t *testing.T) {
+	created, code := createAndTestRandomUserTx(t)
+	verification, err := testQueries.GetEmailVerification(context.Background(), GetEmailVerificationParams{
+		UserID:   created.User.ID,
+		CodeHash: util.HashToken(code),
+	})
+	assert.NoError(t, err)
+	verified, err := testStore.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailVerificationID: verification.ID,
+	})
+	assert.NoError(t, err)
+
+	args := UpdateUserParams{
+		ID:       created.User.ID,
+		Phone:    created.User.Phone,
+		FullName: created.User.FullName,
+		Gender:   created.User.Gender,
+		Email:    strings.ToUpper(created.User.Email),
+	}
+
+	// the same email in other casing stays verified
+	updated, err := testQueries.UpdateUser(context.Background(), args)
+	assert.NoError(t, err)
+	assert.Equal(t, verified.User.EmailVerifiedAt, updated.EmailVerifiedAt)
+
+	args.Email = util.RandomEmail()
+	updated, err = testQueries.UpdateUser(context.Background(), args)
+	assert.NoError(t, err)
+	assert.False(t, updated.EmailVerifiedAt.Valid)
+}
+
+func TestCountEmailVerificationsSince(t *testing.T) {
+	created, _ := createAndTestRandomUserTx(t)
+
+	_, err := testQueries.CreateEmailVerification(context.Background(), CreateEmailVerificationParams{
+		UserID:    created.User.ID,
+		Email:     created.User.Email,
+		CodeHash:  util.HashToken(util.RandomString(32)),
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	})
+	assert.NoError(t, err)
+
+	count, err := testQueries.CountEmailVerificationsSince(context.Background(), CountEmailVerificationsSinceParams{
+		UserID: created.User.ID,
+		Since:  pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, count.Sent)
+	assert.WithinDuration(t, created.EmailVerification.CreatedAt.Time, count.FirstSentAt.Time, time.Millisecond)
+	assert.WithinDuration(t, time.Now(), count.LastSentAt.Time, time.Minute)
+
+	count, err = testQueries.CountEmailVerificationsSince(context.Background(), CountEmailVerificationsSinceParams{
+		UserID: created.User.ID,
+		Since:  pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+	})
+	assert.NoError(t, err)
+	assert.Zero(t, count.Sent)
+	assert.False(t, count.LastSentAt.Valid)
+}
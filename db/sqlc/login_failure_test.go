@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordLoginFailure(t *testing.T) {
+	key := "username:" + util.RandomUsername()
+	windowStart := pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+
+	for i := int32(1); i <= 3; i++ {
+		failure, err := testQueries.RecordLoginFailure(context.Background(), RecordLoginFailureParams{
+			Key:         key,
+			WindowStart: windowStart,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, key, failure.Key)
+		assert.Equal(t, i, failure.Failures)
+		assert.WithinDuration(t, time.Now(), failure.LastFailedAt.Time, time.Second)
+	}
+
+	// failures before the window start are not counted
+	failure, err := testQueries.RecordLoginFailure(context.Background(), RecordLoginFailureParams{
+		Key:         key,
+		WindowStart: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), failure.Failures)
+
+	got, err := testQueries.GetLoginFailure(context.Background(), key)
+	assert.NoError(t, err)
+	assert.Equal(t, failure, got)
+
+	err = testQueries.ResetLoginFailures(context.Background(), []string{key})
+	assert.NoError(t, err)
+
+	_, err = testQueries.GetLoginFailure(context.Background(), key)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
@@ -12,6 +12,7 @@ import (
 
 var testQueries *Queries
 var testDB *pgxpool.Pool
+var testStore Store
 
 func TestMain(m *testing.M) {
 	config, err := config.LoadConfig("app", "env", "../../config")
@@ -20,12 +21,13 @@ func TestMain(m *testing.M) {
 	}
 
 	ctx := context.Background()
-	testDB, err := pgxpool.New(ctx, config.DBSource)
+	testDB, err = pgxpool.New(ctx, config.DBSource)
 	if err != nil {
 		log.Fatalf("unable to connect to db:%+v", err)
 	}
 	defer testDB.Close()
 
 	testQueries = New(testDB)
+	testStore = NewStore(testDB)
 	os.Exit(m.Run())
 }
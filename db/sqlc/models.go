@@ -5,20 +5,124 @@
 package db
 
 import (
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID          `json:"id"`
+	UserID     int64              `json:"user_id"`
+	Name       string             `json:"name"`
+	Prefix     string             `json:"prefix"`
+	KeyHash    string             `json:"key_hash"`
+	Scopes     []string           `json:"scopes"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	RevokedAt  pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type EmailVerification struct {
+	ID        int64              `json:"id"`
+	UserID    int64              `json:"user_id"`
+	Email     string             `json:"email"`
+	CodeHash  string             `json:"code_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type LoginFailure struct {
+	Key          string             `json:"key"`
+	Failures     int32              `json:"failures"`
+	LastFailedAt pgtype.Timestamptz `json:"last_failed_at"`
+}
+
+type MfaChallenge struct {
+	ID        int64              `json:"id"`
+	UserID    int64              `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	Attempts  int32              `json:"attempts"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Scopes    []string           `json:"scopes"`
+}
+
+type PasswordResetToken struct {
+	ID        int64              `json:"id"`
+	UserID    int64              `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type PhoneVerification struct {
+	ID        int64              `json:"id"`
+	UserID    int64              `json:"user_id"`
+	Phone     string             `json:"phone"`
+	CodeHash  string             `json:"code_hash"`
+	Attempts  int32              `json:"attempts"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type RevokedToken struct {
+	ID        uuid.UUID          `json:"id"`
+	Username  string             `json:"username"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
+}
+
+type Session struct {
+	ID           uuid.UUID          `json:"id"`
+	Username     string             `json:"username"`
+	RefreshToken string             `json:"refresh_token"`
+	UserAgent    string             `json:"user_agent"`
+	ClientIp     string             `json:"client_ip"`
+	IsBlocked    bool               `json:"is_blocked"`
+	ExpiresAt    pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+}
+
+type TotpRecoveryCode struct {
+	ID        int64              `json:"id"`
+	UserID    int64              `json:"user_id"`
+	CodeHash  string             `json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
 type User struct {
-	ID                int64              `json:"id"`
-	Username          string             `json:"username"`
-	FullName          string             `json:"full_name"`
-	Gender            string             `json:"gender"`
-	Age               int32              `json:"age"`
-	Email             string             `json:"email"`
-	Phone             string             `json:"phone"`
-	HashedPassword    string             `json:"hashed_password"`
-	Avatar            string             `json:"avatar"`
-	Status            string             `json:"status"`
-	PasswordChangedAt pgtype.Timestamptz `json:"password_changed_at"`
-	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	ID                   int64              `json:"id"`
+	Username             string             `json:"username"`
+	FullName             string             `json:"full_name"`
+	Gender               string             `json:"gender"`
+	Age                  int32              `json:"age"`
+	Email                string             `json:"email"`
+	Phone                string             `json:"phone"`
+	HashedPassword       string             `json:"hashed_password"`
+	Avatar               string             `json:"avatar"`
+	Status               string             `json:"status"`
+	PasswordChangedAt    pgtype.Timestamptz `json:"password_changed_at"`
+	CreatedAt            pgtype.Timestamptz `json:"created_at"`
+	EmailVerifiedAt      pgtype.Timestamptz `json:"email_verified_at"`
+	PhoneVerifiedAt      pgtype.Timestamptz `json:"phone_verified_at"`
+	Role                 string             `json:"role"`
+	DeletedAt            pgtype.Timestamptz `json:"deleted_at"`
+	StatusReason         string             `json:"status_reason"`
+	StatusExpiresAt      pgtype.Timestamptz `json:"status_expires_at"`
+	DisplayUsername      string             `json:"display_username"`
+	TokensValidAfter     pgtype.Timestamptz `json:"tokens_valid_after"`
+	StatusBeforeDeletion string             `json:"status_before_deletion"`
+}
+
+type UserTotp struct {
+	UserID       int64              `json:"user_id"`
+	Secret       string             `json:"secret"`
+	ConfirmedAt  pgtype.Timestamptz `json:"confirmed_at"`
+	LastUsedStep int64              `json:"last_used_step"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
 }
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func createAndTestRandomResetToken(t *testing.T, user User, expiresAt time.Time) PasswordResetToken {
+	args := CreatePasswordResetTokenParams{
+		UserID:    user.ID,
+		TokenHash: util.HashToken(util.RandomString(32)),
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	}
+
+	resetToken, err := testQueries.CreatePasswordResetToken(context.Background(), args)
+	assert.NoError(t, err)
+	assert.NotZero(t, resetToken.ID)
+	assert.Equal(t, args.UserID, resetToken.UserID)
+	assert.Equal(t, args.TokenHash, resetToken.TokenHash)
+	assert.WithinDuration(t, expiresAt, resetToken.ExpiresAt.Time, time.Second)
+	assert.False(t, resetToken.UsedAt.Valid)
+
+	return resetToken
+}
+
+func TestGetPasswordResetToken(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	resetToken := createAndTestRandomResetToken(t, user, time.Now().Add(time.Minute))
+
+	got, err := testQueries.GetPasswordResetToken(context.Background(), resetToken.TokenHash)
+	assert.NoError(t, err)
+	assert.Equal(t, resetToken.ID, got.ID)
+
+	expired := createAndTestRandomResetToken(t, user, time.Now().Add(-time.Minute))
+	_, err = testQueries.GetPasswordResetToken(context.Background(), expired.TokenHash)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestCountPasswordResetTokensSince(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	first := createAndTestRandomResetToken(t, user, time.Now().Add(time.Minute))
+	createAndTestRandomResetToken(t, user, time.Now().Add(time.Minute))
+
+	count, err := testQueries.CountPasswordResetTokensSince(context.Background(), CountPasswordResetTokensSinceParams{
+		UserID: user.ID,
+		Since:  pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, count.Sent)
+	assert.WithinDuration(t, first.CreatedAt.Time, count.FirstSentAt.Time, time.Millisecond)
+	assert.WithinDuration(t, time.Now(), count.LastSentAt.Time, time.Minute)
+
+	count, err = testQueries.CountPasswordResetTokensSince(context.Background(), CountPasswordResetTokensSinceParams{
+		UserID: user.ID,
+		Since:  pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+	})
+	assert.NoError(t, err)
+	assert.Zero(t, count.Sent)
+	assert.False(t, count.LastSentAt.Valid)
+}
+
+func TestResetPasswordTx(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	resetToken := createAndTestRandomResetToken(t, user, time.Now().Add(time.Minute))
+	otherToken := createAndTestRandomResetToken(t, user, time.Now().Add(time.Minute))
+
+	hashedPassword, err := util.HashPassword(util.RandomString(12))
+	assert.NoError(t, err)
+
+	args := ResetPasswordTxParams{
+		ResetTokenID:      resetToken.ID,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+	result, err := testStore.ResetPasswordTx(context.Background(), args)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, result.User.ID)
+	assert.Equal(t, hashedPassword, result.User.HashedPassword)
+	assert.WithinDuration(t, args.PasswordChangedAt.Time, result.User.PasswordChangedAt.Time, time.Second)
+
+	// a used token can not be used again
+	_, err = testStore.ResetPasswordTx(context.Background(), args)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	_, err = testQueries.GetPasswordResetToken(context.Background(), resetToken.TokenHash)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// the other tokens of the user are used up too
+	_, err = testQueries.GetPasswordResetToken(context.Background(), otherToken.TokenHash)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	args.ResetTokenID = otherToken.ID
+	_, err = testStore.ResetPasswordTx(context.Background(), args)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func createAndTestRandomPhoneVerification(t *testing.T, user User) PhoneVerification {
+	args := CreatePhoneVerificationParams{
+		UserID:    user.ID,
+		Phone:     user.Phone,
+		CodeHash:  util.HashToken(util.RandomString(6)),
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+	}
+
+	verification, err := testQueries.CreatePhoneVerification(context.Background(), args)
+	assert.NoError(t, err)
+	assert.Equal(t, args.UserID, verification.UserID)
+	assert.Equal(t, args.Phone, verification.Phone)
+	assert.Equal(t, args.CodeHash, verification.CodeHash)
+	assert.Zero(t, verification.Attempts)
+
+	return verification
+}
+
+func TestGetActivePhoneVerification(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	_ = createAndTestRandomPhoneVerification(t, user)
+	latest := createAndTestRandomPhoneVerification(t, user)
+
+	got, err := testQueries.GetActivePhoneVerification(context.Background(), GetActivePhoneVerificationParams{
+		UserID: user.ID,
+		Phone:  user.Phone,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, latest.ID, got.ID)
+}
+
+func TestCountPhoneVerificationsSince(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	since := pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true}
+
+	count, err := testQueries.CountPhoneVerificationsSince(context.Background(), CountPhoneVerificationsSinceParams{
+		UserID: user.ID,
+		Since:  since,
+	})
+	assert.NoError(t, err)
+	assert.Zero(t, count.Sent)
+	assert.False(t, count.LastSentAt.Valid)
+
+	first := createAndTestRandomPhoneVerification(t, user)
+	last := createAndTestRandomPhoneVerification(t, user)
+
+	count, err = testQueries.CountPhoneVerificationsSince(context.Background(), CountPhoneVerificationsSinceParams{
+		UserID: user.ID,
+		Since:  since,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count.Sent)
+	assert.WithinDuration(t, first.CreatedAt.Time, count.FirstSentAt.Time, time.Millisecond)
+	assert.WithinDuration(t, last.CreatedAt.Time, count.LastSentAt.Time, time.Millisecond)
+}
+
+func TestIncrementPhoneVerificationAttempts(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	verification := createAndTestRandomPhoneVerification(t, user)
+
+	args := IncrementPhoneVerificationAttemptsParams{
+		ID:          verification.ID,
+		Since:       pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+		MaxAttempts: 2,
+	}
+	for i := int32(1); i <= args.MaxAttempts; i++ {
+		got, err := testQueries.IncrementPhoneVerificationAttempts(context.Background(), args)
+		assert.NoError(t, err)
+		assert.Equal(t, i, got.Attempts)
+	}
+
+	_, err := testQueries.IncrementPhoneVerificationAttempts(context.Background(), args)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// a new code does not bring new attempts
+	args.ID = createAndTestRandomPhoneVerification(t, user).ID
+	_, err = testQueries.IncrementPhoneVerificationAttempts(context.Background(), args)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// attempts of codes sent before the window do not count
+	args.Since = pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true}
+	_, err = testQueries.IncrementPhoneVerificationAttempts(context.Background(), args)
+	assert.NoError(t, err)
+}
+
+func TestVerifyPhoneTx(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	verification := createAndTestRandomPhoneVerification(t, user)
+
+	result, err := testStore.VerifyPhoneTx(context.Background(), VerifyPhoneTxParams{
+		PhoneVerificationID: verification.ID,
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.User.PhoneVerifiedAt.Valid)
+
+	_, err = testStore.VerifyPhoneTx(context.Background(), VerifyPhoneTxParams{
+		PhoneVerificationID: verification.ID,
+	})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// a new phone number has to be verified again
+	updated, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		ID:       user.ID,
+		Phone:    util.RandomPhone(),
+		FullName: user.FullName,
+		Gender:   user.Gender,
+		Email:    user.Email,
+	})
+	assert.NoError(t, err)
+	assert.False(t, updated.PhoneVerifiedAt.Valid)
+}
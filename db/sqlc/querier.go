@@ -6,15 +6,75 @@ package db
 
 import (
 	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	BlockSession(ctx context.Context, id uuid.UUID) (Session, error)
+	ConfirmUserTotp(ctx context.Context, userID int64) (UserTotp, error)
+	CountEmailVerificationsSince(ctx context.Context, arg CountEmailVerificationsSinceParams) (CountEmailVerificationsSinceRow, error)
+	CountPasswordResetTokensSince(ctx context.Context, arg CountPasswordResetTokensSinceParams) (CountPasswordResetTokensSinceRow, error)
+	CountPhoneVerificationsSince(ctx context.Context, arg CountPhoneVerificationsSinceParams) (CountPhoneVerificationsSinceRow, error)
+	CreateApiKey(ctx context.Context, arg CreateApiKeyParams) (ApiKey, error)
+	CreateEmailVerification(ctx context.Context, arg CreateEmailVerificationParams) (EmailVerification, error)
+	CreateMfaChallenge(ctx context.Context, arg CreateMfaChallengeParams) (MfaChallenge, error)
+	CreatePasswordResetToken(ctx context.Context, arg CreatePasswordResetTokenParams) (PasswordResetToken, error)
+	CreatePhoneVerification(ctx context.Context, arg CreatePhoneVerificationParams) (PhoneVerification, error)
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateTotpRecoveryCode(ctx context.Context, arg CreateTotpRecoveryCodeParams) error
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
-	DeleteUserByID(ctx context.Context, id int64) error
+	DeleteStaleLoginFailures(ctx context.Context, windowStart pgtype.Timestamptz) error
+	DeleteTotpRecoveryCodes(ctx context.Context, userID int64) error
+	DeleteUserSessions(ctx context.Context, usernames []string) error
+	GetActivePhoneVerification(ctx context.Context, arg GetActivePhoneVerificationParams) (PhoneVerification, error)
+	GetApiKeyByHash(ctx context.Context, keyHash string) (ApiKey, error)
+	GetDeletedUserByUsername(ctx context.Context, username string) (User, error)
+	GetEmailVerification(ctx context.Context, arg GetEmailVerificationParams) (EmailVerification, error)
+	GetLoginFailure(ctx context.Context, key string) (LoginFailure, error)
+	GetMfaChallenge(ctx context.Context, tokenHash string) (MfaChallenge, error)
+	GetPasswordResetToken(ctx context.Context, tokenHash string) (PasswordResetToken, error)
+	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
+	GetUserAuthState(ctx context.Context, username string) (GetUserAuthStateRow, error)
+	GetUserByEmail(ctx context.Context, lower string) (User, error)
 	GetUserByID(ctx context.Context, id int64) (User, error)
 	GetUserByUsername(ctx context.Context, username string) (User, error)
 	GetUserByUsernameForUpdate(ctx context.Context, username string) (User, error)
+	GetUserTotp(ctx context.Context, userID int64) (UserTotp, error)
+	IncrementMfaChallengeAttempts(ctx context.Context, arg IncrementMfaChallengeAttemptsParams) (MfaChallenge, error)
+	IncrementPhoneVerificationAttempts(ctx context.Context, arg IncrementPhoneVerificationAttemptsParams) (PhoneVerification, error)
+	ListApiKeys(ctx context.Context, userID int64) ([]ApiKey, error)
+	ListRevokedTokensSince(ctx context.Context, revokedAt pgtype.Timestamptz) ([]RevokedToken, error)
+	ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error)
+	ListUsersByPhone(ctx context.Context, lower string) ([]User, error)
+	ListUsersDesc(ctx context.Context, arg ListUsersDescParams) ([]User, error)
+	PurgeDeletedUsers(ctx context.Context, deletedBefore pgtype.Timestamptz) ([]string, error)
+	RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginFailure, error)
+	RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error
+	ResetLoginFailures(ctx context.Context, keys []string) error
+	RestoreUser(ctx context.Context, arg RestoreUserParams) (User, error)
+	RevokeApiKey(ctx context.Context, arg RevokeApiKeyParams) (ApiKey, error)
+	RevokeToken(ctx context.Context, arg RevokeTokenParams) error
+	RevokeUserSessions(ctx context.Context, username string) ([]RevokedToken, error)
+	RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) (User, error)
+	SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error)
+	SoftDeleteUser(ctx context.Context, id int64) (User, error)
+	TouchApiKey(ctx context.Context, id uuid.UUID) error
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error)
+	UpdateUserStatus(ctx context.Context, arg UpdateUserStatusParams) (User, error)
+	UpsertUserTotp(ctx context.Context, arg UpsertUserTotpParams) (UserTotp, error)
+	UseEmailVerification(ctx context.Context, id int64) (EmailVerification, error)
+	UseMfaChallenge(ctx context.Context, id int64) (MfaChallenge, error)
+	UsePasswordResetToken(ctx context.Context, id int64) (PasswordResetToken, error)
+	UsePhoneVerification(ctx context.Context, id int64) (PhoneVerification, error)
+	UseTotpRecoveryCode(ctx context.Context, arg UseTotpRecoveryCodeParams) (TotpRecoveryCode, error)
+	UseUserPasswordResetTokens(ctx context.Context, userID int64) error
+	UseUserTotpStep(ctx context.Context, arg UseUserTotpStepParams) (UserTotp, error)
+	VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error)
+	VerifyUserPhone(ctx context.Context, arg VerifyUserPhoneParams) (User, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevokeToken(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	since := pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+
+	args := RevokeTokenParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+	err := testQueries.RevokeToken(context.Background(), args)
+	assert.NoError(t, err)
+
+	// revoking twice is not an error
+	err = testQueries.RevokeToken(context.Background(), args)
+	assert.NoError(t, err)
+
+	revoked, err := testQueries.ListRevokedTokensSince(context.Background(), since)
+	assert.NoError(t, err)
+
+	var found bool
+	for _, r := range revoked {
+		if r.ID == args.ID {
+			found = true
+			assert.Equal(t, args.Username, r.Username)
+			assert.NotZero(t, r.RevokedAt)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	session1 := createAndTestRandomSession(t, user)
+	session2 := createAndTestRandomSession(t, user)
+
+	revoked, err := testQueries.RevokeUserSessions(context.Background(), user.Username)
+	assert.NoError(t, err)
+	assert.Len(t, revoked, 2)
+
+	for _, id := range []uuid.UUID{session1.ID, session2.ID} {
+		session, err := testQueries.GetSession(context.Background(), id)
+		assert.NoError(t, err)
+		assert.True(t, session.IsBlocked)
+	}
+
+	// already blocked sessions are not revoked again
+	revoked, err = testQueries.RevokeUserSessions(context.Background(), user.Username)
+	assert.NoError(t, err)
+	assert.Empty(t, revoked)
+}
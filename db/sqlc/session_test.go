@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func createAndTestRandomSession(t *testing.T, user User) Session {
+	args := CreateSessionParams{
+		ID:           uuid.New(),
+		Username:     user.Username,
+		RefreshToken: util.RandomString(32),
+		UserAgent:    util.RandomString(10),
+		ClientIp:     "127.0.0.1",
+		IsBlocked:    false,
+		ExpiresAt:    pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+
+	session, err := testQueries.CreateSession(context.Background(), args)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, session)
+
+	assert.Equal(t, args.ID, session.ID)
+	assert.Equal(t, args.Username, session.Username)
+	assert.Equal(t, args.RefreshToken, session.RefreshToken)
+	assert.Equal(t, args.UserAgent, session.UserAgent)
+	assert.Equal(t, args.ClientIp, session.ClientIp)
+	assert.False(t, session.IsBlocked)
+	assert.WithinDuration(t, args.ExpiresAt.Time, session.ExpiresAt.Time, time.Second)
+	assert.NotZero(t, session.CreatedAt)
+
+	return session
+}
+
+func TestCreateSession(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	_ = createAndTestRandomSession(t, user)
+}
+
+func TestGetSession(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	session := createAndTestRandomSession(t, user)
+
+	gotSession, err := testQueries.GetSession(context.Background(), session.ID)
+	assert.NoError(t, err)
+
+	assert.Equal(t, session.ID, gotSession.ID)
+	assert.Equal(t, session.Username, gotSession.Username)
+	assert.Equal(t, session.RefreshToken, gotSession.RefreshToken)
+	assert.Equal(t, session.IsBlocked, gotSession.IsBlocked)
+	assert.Equal(t, session.ExpiresAt, gotSession.ExpiresAt)
+	assert.Equal(t, session.CreatedAt, gotSession.CreatedAt)
+}
@@ -1,11 +1,21 @@
 package db
 
-import "github.com/jackc/pgx/v5/pgxpool"
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
 
 // Store defines all methods to exec queries and transactions
-// TODO: for future transactions
 type Store interface {
 	Querier
+	ConfirmTotpTx(ctx context.Context, arg ConfirmTotpTxParams) (ConfirmTotpTxResult, error)
+	CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error)
+	PurgeDeletedUsersTx(ctx context.Context, arg PurgeDeletedUsersTxParams) (PurgeDeletedUsersTxResult, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error)
+	VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error)
+	VerifyPhoneTx(ctx context.Context, arg VerifyPhoneTxParams) (VerifyPhoneTxResult, error)
 }
 
 type PSQLSTore struct {
@@ -19,3 +29,21 @@ func NewStore(db *pgxpool.Pool) Store {
 		Queries: New(db),
 	}
 }
+
+// execTx runs fn inside a database transaction
+func (store *PSQLSTore) execTx(ctx context.Context, fn func(*Queries) error) error {
+	tx, err := store.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(store.Queries.WithTx(tx))
+	if err != nil {
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			return fmt.Errorf("tx err: %v, rollback err: %v", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
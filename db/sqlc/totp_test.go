@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfirmTotpTx(t *testing.T) {
+	user := createAndTestRandomUser(t)
+
+	secret, err := util.NewTOTPSecret()
+	assert.NoError(t, err)
+	totp, err := testQueries.UpsertUserTotp(context.Background(), UpsertUserTotpParams{
+		UserID: user.ID,
+		Secret: secret,
+	})
+	assert.NoError(t, err)
+	assert.False(t, totp.ConfirmedAt.Valid)
+
+	codes := []string{util.RandomString(12), util.RandomString(12)}
+	step := util.TOTPStep(time.Now())
+	result, err := testStore.ConfirmTotpTx(context.Background(), ConfirmTotpTxParams{
+		UserID:             user.ID,
+		Step:               step,
+		RecoveryCodeHashes: []string{util.HashToken(codes[0]), util.HashToken(codes[1])},
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.UserTotp.ConfirmedAt.Valid)
+	assert.Equal(t, step, result.UserTotp.LastUsedStep)
+
+	// a confirmed secret is not replaced by a new enrollment
+	_, err = testQueries.UpsertUserTotp(context.Background(), UpsertUserTotpParams{
+		UserID: user.ID,
+		Secret: secret,
+	})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// the same step can't be used twice
+	_, err = testQueries.UseUserTotpStep(context.Background(), UseUserTotpStepParams{
+		UserID:       user.ID,
+		LastUsedStep: step,
+	})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// recovery codes are single-use
+	args := UseTotpRecoveryCodeParams{UserID: user.ID, CodeHash: util.HashToken(codes[0])}
+	_, err = testQueries.UseTotpRecoveryCode(context.Background(), args)
+	assert.NoError(t, err)
+	_, err = testQueries.UseTotpRecoveryCode(context.Background(), args)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestMfaChallenge(t *testing.T) {
+	user := createAndTestRandomUser(t)
+
+	challenge, err := testQueries.CreateMfaChallenge(context.Background(), CreateMfaChallengeParams{
+		UserID:    user.ID,
+		TokenHash: util.HashToken(util.RandomString(32)),
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+		Scopes:    []string{util.ScopeUsersRead},
+	})
+	assert.NoError(t, err)
+
+	got, err := testQueries.GetMfaChallenge(context.Background(), challenge.TokenHash)
+	assert.NoError(t, err)
+	assert.Equal(t, challenge.ID, got.ID)
+	assert.Equal(t, []string{util.ScopeUsersRead}, got.Scopes)
+
+	_, err = testQueries.UseMfaChallenge(context.Background(), challenge.ID)
+	assert.NoError(t, err)
+
+	_, err = testQueries.GetMfaChallenge(context.Background(), challenge.TokenHash)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
@@ -0,0 +1,51 @@
+package db
+
+import "context"
+
+type ConfirmTotpTxParams struct {
+	UserID int64
+	// Step is the TOTP period of the code the user confirmed with
+	Step               int64
+	RecoveryCodeHashes []string
+}
+
+type ConfirmTotpTxResult struct {
+	UserTotp UserTotp
+}
+
+// ConfirmTotpTx turns on 2FA for the user and replaces its recovery codes.
+// If the TOTP is already confirmed or the step was used, pgx.ErrNoRows is returned.
+func (store *PSQLSTore) ConfirmTotpTx(ctx context.Context, arg ConfirmTotpTxParams) (ConfirmTotpTxResult, error) {
+	var result ConfirmTotpTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		_, err := q.ConfirmUserTotp(ctx, arg.UserID)
+		if err != nil {
+			return err
+		}
+
+		result.UserTotp, err = q.UseUserTotpStep(ctx, UseUserTotpStepParams{
+			UserID:       arg.UserID,
+			LastUsedStep: arg.Step,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = q.DeleteTotpRecoveryCodes(ctx, arg.UserID); err != nil {
+			return err
+		}
+		for _, codeHash := range arg.RecoveryCodeHashes {
+			err = q.CreateTotpRecoveryCode(ctx, CreateTotpRecoveryCodeParams{
+				UserID:   arg.UserID,
+				CodeHash: codeHash,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return result, err
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type CreateUserTxParams struct {
+	CreateUserParams
+	// VerificationCodeHash is stored to verify the email of the new user
+	VerificationCodeHash      string
+	VerificationCodeExpiresAt pgtype.Timestamptz
+}
+
+type CreateUserTxResult struct {
+	User              User
+	EmailVerification EmailVerification
+}
+
+// CreateUserTx creates a user together with the code verifying its email.
+// The code is sent by the caller once the user is committed, so a slow mail
+// server does not hold the transaction and no code is sent for a user whose
+// commit failed.
+func (store *PSQLSTore) CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error) {
+	var result CreateUserTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.CreateUser(ctx, arg.CreateUserParams)
+		if err != nil {
+			return err
+		}
+
+		result.EmailVerification, err = q.CreateEmailVerification(ctx, CreateEmailVerificationParams{
+			UserID:    result.User.ID,
+			Email:     result.User.Email,
+			CodeHash:  arg.VerificationCodeHash,
+			ExpiresAt: arg.VerificationCodeExpiresAt,
+		})
+		return err
+	})
+
+	return result, err
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type PurgeDeletedUsersTxParams struct {
+	DeletedBefore pgtype.Timestamptz
+}
+
+type PurgeDeletedUsersTxResult struct {
+	Usernames []string
+}
+
+// PurgeDeletedUsersTx permanently removes users soft deleted before the given
+// time along with their sessions. Everything else of the users is removed by
+// foreign key cascades. Revoked tokens are kept until they expire.
+func (store *PSQLSTore) PurgeDeletedUsersTx(ctx context.Context, arg PurgeDeletedUsersTxParams) (PurgeDeletedUsersTxResult, error) {
+	var result PurgeDeletedUsersTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result.Usernames, err = q.PurgeDeletedUsers(ctx, arg.DeletedBefore)
+		if err != nil || len(result.Usernames) == 0 {
+			return err
+		}
+
+		return q.DeleteUserSessions(ctx, result.Usernames)
+	})
+
+	return result, err
+}
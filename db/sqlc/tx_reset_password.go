@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type ResetPasswordTxParams struct {
+	ResetTokenID      int64
+	HashedPassword    string
+	PasswordChangedAt pgtype.Timestamptz
+}
+
+type ResetPasswordTxResult struct {
+	User User
+}
+
+// ResetPasswordTx uses up the reset token and sets the new password of its user.
+// The other reset tokens of the user are used up too, so an older email can
+// not reset the password again.
+// If the token was already used or is expired, pgx.ErrNoRows is returned.
+func (store *PSQLSTore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error) {
+	var result ResetPasswordTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		resetToken, err := q.UsePasswordResetToken(ctx, arg.ResetTokenID)
+		if err != nil {
+			return err
+		}
+
+		err = q.UseUserPasswordResetTokens(ctx, resetToken.UserID)
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			ID:                resetToken.UserID,
+			HashedPassword:    arg.HashedPassword,
+			PasswordChangedAt: arg.PasswordChangedAt,
+		})
+		return err
+	})
+
+	return result, err
+}
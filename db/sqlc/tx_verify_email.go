@@ -0,0 +1,34 @@
+package db
+
+import "context"
+
+type VerifyEmailTxParams struct {
+	EmailVerificationID int64
+}
+
+type VerifyEmailTxResult struct {
+	User User
+}
+
+// VerifyEmailTx uses up the verification code and marks the email of its user
+// as verified, activating the user if it was pending verification.
+// If the code was already used, is expired or was sent to an email the user
+// no longer has, pgx.ErrNoRows is returned.
+func (store *PSQLSTore) VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error) {
+	var result VerifyEmailTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		verification, err := q.UseEmailVerification(ctx, arg.EmailVerificationID)
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.VerifyUserEmail(ctx, VerifyUserEmailParams{
+			ID:    verification.UserID,
+			Email: verification.Email,
+		})
+		return err
+	})
+
+	return result, err
+}
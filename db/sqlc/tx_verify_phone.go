@@ -0,0 +1,33 @@
+package db
+
+import "context"
+
+type VerifyPhoneTxParams struct {
+	PhoneVerificationID int64
+}
+
+type VerifyPhoneTxResult struct {
+	User User
+}
+
+// VerifyPhoneTx uses up the OTP and marks the phone of its user as verified.
+// If the OTP was already used, is expired or was sent to a number the user
+// no longer has, pgx.ErrNoRows is returned.
+func (store *PSQLSTore) VerifyPhoneTx(ctx context.Context, arg VerifyPhoneTxParams) (VerifyPhoneTxResult, error) {
+	var result VerifyPhoneTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		verification, err := q.UsePhoneVerification(ctx, arg.PhoneVerificationID)
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.VerifyUserPhone(ctx, VerifyUserPhoneParams{
+			ID:    verification.UserID,
+			Phone: verification.Phone,
+		})
+		return err
+	})
+
+	return result, err
+}
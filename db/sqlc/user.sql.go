@@ -7,6 +7,8 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -17,20 +19,22 @@ INSERT INTO users (
     age,
     email,
     phone,
-    hashed_password
+    hashed_password,
+    display_username
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
-) RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion
 `
 
 type CreateUserParams struct {
-	Username       string `json:"username"`
-	FullName       string `json:"full_name"`
-	Gender         string `json:"gender"`
-	Age            int32  `json:"age"`
-	Email          string `json:"email"`
-	Phone          string `json:"phone"`
-	HashedPassword string `json:"hashed_password"`
+	Username        string `json:"username"`
+	FullName        string `json:"full_name"`
+	Gender          string `json:"gender"`
+	Age             int32  `json:"age"`
+	Email           string `json:"email"`
+	Phone           string `json:"phone"`
+	HashedPassword  string `json:"hashed_password"`
+	DisplayUsername string `json:"display_username"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -42,6 +46,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.Email,
 		arg.Phone,
 		arg.HashedPassword,
+		arg.DisplayUsername,
 	)
 	var i User
 	err := row.Scan(
@@ -57,23 +62,116 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Status,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
 	)
 	return i, err
 }
 
-const deleteUserByID = `-- name: DeleteUserByID :exec
-DELETE FROM users
-WHERE id = $1
+const getDeletedUserByUsername = `-- name: GetDeletedUserByUsername :one
+SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion FROM users
+WHERE username = $1 AND deleted_at IS NOT NULL LIMIT 1
 `
 
-func (q *Queries) DeleteUserByID(ctx context.Context, id int64) error {
-	_, err := q.db.Exec(ctx, deleteUserByID, id)
-	return err
+func (q *Queries) GetDeletedUserByUsername(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRow(ctx, getDeletedUserByUsername, username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Gender,
+		&i.Age,
+		&i.Email,
+		&i.Phone,
+		&i.HashedPassword,
+		&i.Avatar,
+		&i.Status,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
+	)
+	return i, err
+}
+
+const getUserAuthState = `-- name: GetUserAuthState :one
+SELECT password_changed_at, status, status_expires_at, email_verified_at, tokens_valid_after FROM users
+WHERE username = $1 AND deleted_at IS NULL LIMIT 1
+`
+
+type GetUserAuthStateRow struct {
+	PasswordChangedAt pgtype.Timestamptz `json:"password_changed_at"`
+	Status            string             `json:"status"`
+	StatusExpiresAt   pgtype.Timestamptz `json:"status_expires_at"`
+	EmailVerifiedAt   pgtype.Timestamptz `json:"email_verified_at"`
+	TokensValidAfter  pgtype.Timestamptz `json:"tokens_valid_after"`
+}
+
+func (q *Queries) GetUserAuthState(ctx context.Context, username string) (GetUserAuthStateRow, error) {
+	row := q.db.QueryRow(ctx, getUserAuthState, username)
+	var i GetUserAuthStateRow
+	err := row.Scan(
+		&i.PasswordChangedAt,
+		&i.Status,
+		&i.StatusExpiresAt,
+		&i.EmailVerifiedAt,
+		&i.TokensValidAfter,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion FROM users
+WHERE lower(email) = lower($1) AND deleted_at IS NULL LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, lower string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, lower)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Gender,
+		&i.Age,
+		&i.Email,
+		&i.Phone,
+		&i.HashedPassword,
+		&i.Avatar,
+		&i.Status,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
+	)
+	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at FROM users
-WHERE id = $1 LIMIT 1
+SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion FROM users
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
@@ -92,13 +190,22 @@ func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
 		&i.Status,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
 	)
 	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at FROM users
-WHERE username = $1 LIMIT 1
+SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion FROM users
+WHERE username = $1 AND deleted_at IS NULL LIMIT 1
 `
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
@@ -117,13 +224,22 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.Status,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
 	)
 	return i, err
 }
 
 const getUserByUsernameForUpdate = `-- name: GetUserByUsernameForUpdate :one
-SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at FROM users
-WHERE username = $1 LIMIT 1
+SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion FROM users
+WHERE username = $1 AND deleted_at IS NULL LIMIT 1
 FOR NO KEY UPDATE
 `
 
@@ -143,6 +259,471 @@ func (q *Queries) GetUserByUsernameForUpdate(ctx context.Context, username strin
 		&i.Status,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
+	)
+	return i, err
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion FROM users
+WHERE deleted_at IS NULL
+    AND ($1::varchar IS NULL OR CASE
+        WHEN status IN ('suspended', 'banned', 'locked') AND status_expires_at <= now()
+            THEN CASE WHEN email_verified_at IS NULL THEN 'pending_verification' ELSE 'active' END
+        ELSE status
+    END = $1)
+    AND ($2::varchar IS NULL OR gender = $2)
+    AND ($3::int IS NULL OR age >= $3)
+    AND ($4::int IS NULL OR age <= $4)
+    AND ($5::timestamptz IS NULL OR created_at >= $5)
+    AND ($6::timestamptz IS NULL OR created_at < $6)
+    AND ($7::timestamptz IS NULL
+        OR (created_at, id) > ($7, $8::bigint))
+ORDER BY created_at, id
+LIMIT $9
+`
+
+type ListUsersParams struct {
+	Status         pgtype.Text        `json:"status"`
+	Gender         pgtype.Text        `json:"gender"`
+	MinAge         pgtype.Int4        `json:"min_age"`
+	MaxAge         pgtype.Int4        `json:"max_age"`
+	CreatedFrom    pgtype.Timestamptz `json:"created_from"`
+	CreatedTo      pgtype.Timestamptz `json:"created_to"`
+	AfterCreatedAt pgtype.Timestamptz `json:"after_created_at"`
+	AfterID        pgtype.Int8        `json:"after_id"`
+	PageLimit      int32              `json:"page_limit"`
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsers,
+		arg.Status,
+		arg.Gender,
+		arg.MinAge,
+		arg.MaxAge,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.FullName,
+			&i.Gender,
+			&i.Age,
+			&i.Email,
+			&i.Phone,
+			&i.HashedPassword,
+			&i.Avatar,
+			&i.Status,
+			&i.PasswordChangedAt,
+			&i.CreatedAt,
+			&i.EmailVerifiedAt,
+			&i.PhoneVerifiedAt,
+			&i.Role,
+			&i.DeletedAt,
+			&i.StatusReason,
+			&i.StatusExpiresAt,
+			&i.DisplayUsername,
+			&i.TokensValidAfter,
+			&i.StatusBeforeDeletion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByPhone = `-- name: ListUsersByPhone :many
+SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion FROM users
+WHERE lower(phone) = lower($1) AND deleted_at IS NULL
+LIMIT 2
+`
+
+func (q *Queries) ListUsersByPhone(ctx context.Context, lower string) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsersByPhone, lower)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.FullName,
+			&i.Gender,
+			&i.Age,
+			&i.Email,
+			&i.Phone,
+			&i.HashedPassword,
+			&i.Avatar,
+			&i.Status,
+			&i.PasswordChangedAt,
+			&i.CreatedAt,
+			&i.EmailVerifiedAt,
+			&i.PhoneVerifiedAt,
+			&i.Role,
+			&i.DeletedAt,
+			&i.StatusReason,
+			&i.StatusExpiresAt,
+			&i.DisplayUsername,
+			&i.TokensValidAfter,
+			&i.StatusBeforeDeletion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersDesc = `-- name: ListUsersDesc :many
+SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion FROM users
+WHERE deleted_at IS NULL
+    AND ($1::varchar IS NULL OR CASE
+        WHEN status IN ('suspended', 'banned', 'locked') AND status_expires_at <= now()
+            THEN CASE WHEN email_verified_at IS NULL THEN 'pending_verification' ELSE 'active' END
+        ELSE status
+    END = $1)
+    AND ($2::varchar IS NULL OR gender = $2)
+    AND ($3::int IS NULL OR age >= $3)
+    AND ($4::int IS NULL OR age <= $4)
+    AND ($5::timestamptz IS NULL OR created_at >= $5)
+    AND ($6::timestamptz IS NULL OR created_at < $6)
+    AND ($7::timestamptz IS NULL
+        OR (created_at, id) < ($7, $8::bigint))
+ORDER BY created_at DESC, id DESC
+LIMIT $9
+`
+
+type ListUsersDescParams struct {
+	Status         pgtype.Text        `json:"status"`
+	Gender         pgtype.Text        `json:"gender"`
+	MinAge         pgtype.Int4        `json:"min_age"`
+	MaxAge         pgtype.Int4        `json:"max_age"`
+	CreatedFrom    pgtype.Timestamptz `json:"created_from"`
+	CreatedTo      pgtype.Timestamptz `json:"created_to"`
+	AfterCreatedAt pgtype.Timestamptz `json:"after_created_at"`
+	AfterID        pgtype.Int8        `json:"after_id"`
+	PageLimit      int32              `json:"page_limit"`
+}
+
+func (q *Queries) ListUsersDesc(ctx context.Context, arg ListUsersDescParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsersDesc,
+		arg.Status,
+		arg.Gender,
+		arg.MinAge,
+		arg.MaxAge,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.FullName,
+			&i.Gender,
+			&i.Age,
+			&i.Email,
+			&i.Phone,
+			&i.HashedPassword,
+			&i.Avatar,
+			&i.Status,
+			&i.PasswordChangedAt,
+			&i.CreatedAt,
+			&i.EmailVerifiedAt,
+			&i.PhoneVerifiedAt,
+			&i.Role,
+			&i.DeletedAt,
+			&i.StatusReason,
+			&i.StatusExpiresAt,
+			&i.DisplayUsername,
+			&i.TokensValidAfter,
+			&i.StatusBeforeDeletion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deleted_at < $1
+RETURNING username
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedBefore pgtype.Timestamptz) ([]string, error) {
+	rows, err := q.db.Query(ctx, purgeDeletedUsers, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		items = append(items, username)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = $2
+WHERE id = $1 AND hashed_password = $3
+`
+
+type RehashUserPasswordParams struct {
+	ID                int64  `json:"id"`
+	NewHashedPassword string `json:"new_hashed_password"`
+	OldHashedPassword string `json:"old_hashed_password"`
+}
+
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, rehashUserPassword, arg.ID, arg.NewHashedPassword, arg.OldHashedPassword)
+	return err
+}
+
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL,
+    status = CASE
+        WHEN status_before_deletion <> '' THEN status_before_deletion
+        WHEN email_verified_at IS NULL THEN 'pending_verification'
+        ELSE 'active'
+    END,
+    status_before_deletion = ''
+WHERE id = $1 AND deleted_at > $2
+RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion
+`
+
+type RestoreUserParams struct {
+	ID           int64              `json:"id"`
+	DeletedAfter pgtype.Timestamptz `json:"deleted_after"`
+}
+
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, restoreUser, arg.ID, arg.DeletedAfter)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Gender,
+		&i.Age,
+		&i.Email,
+		&i.Phone,
+		&i.HashedPassword,
+		&i.Avatar,
+		&i.Status,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
+	)
+	return i, err
+}
+
+const revokeUserTokens = `-- name: RevokeUserTokens :one
+UPDATE users
+SET tokens_valid_after = $2
+WHERE username = $1 AND deleted_at IS NULL
+RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion
+`
+
+type RevokeUserTokensParams struct {
+	Username         string             `json:"username"`
+	TokensValidAfter pgtype.Timestamptz `json:"tokens_valid_after"`
+}
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) (User, error) {
+	row := q.db.QueryRow(ctx, revokeUserTokens, arg.Username, arg.TokensValidAfter)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Gender,
+		&i.Age,
+		&i.Email,
+		&i.Phone,
+		&i.HashedPassword,
+		&i.Avatar,
+		&i.Status,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
+	)
+	return i, err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion FROM users
+WHERE deleted_at IS NULL
+    AND (full_name ILIKE $1
+        OR username ILIKE $1
+        OR email ILIKE $1
+        OR phone LIKE $1
+        OR $2::text <% full_name
+        OR $2::text <% username
+        OR $2::text <% email)
+ORDER BY greatest(
+        word_similarity($2::text, full_name),
+        word_similarity($2::text, username),
+        word_similarity($2::text, email),
+        word_similarity($2::text, phone)
+    ) DESC, id
+LIMIT $3 OFFSET $4
+`
+
+type SearchUsersParams struct {
+	Pattern    string `json:"pattern"`
+	Query      string `json:"query"`
+	PageLimit  int32  `json:"page_limit"`
+	PageOffset int32  `json:"page_offset"`
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, searchUsers,
+		arg.Pattern,
+		arg.Query,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.FullName,
+			&i.Gender,
+			&i.Age,
+			&i.Email,
+			&i.Phone,
+			&i.HashedPassword,
+			&i.Avatar,
+			&i.Status,
+			&i.PasswordChangedAt,
+			&i.CreatedAt,
+			&i.EmailVerifiedAt,
+			&i.PhoneVerifiedAt,
+			&i.Role,
+			&i.DeletedAt,
+			&i.StatusReason,
+			&i.StatusExpiresAt,
+			&i.DisplayUsername,
+			&i.TokensValidAfter,
+			&i.StatusBeforeDeletion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :one
+UPDATE users
+SET deleted_at = now(),
+    status_before_deletion = status,
+    status = 'deleted'
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion
+`
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRow(ctx, softDeleteUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Gender,
+		&i.Age,
+		&i.Email,
+		&i.Phone,
+		&i.HashedPassword,
+		&i.Avatar,
+		&i.Status,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
 	)
 	return i, err
 }
@@ -152,9 +733,11 @@ UPDATE users
 SET phone = $2,
     full_name = $3,
     gender = $4,
-    email = $5
-WHERE id = $1
-RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at
+    email = $5,
+    phone_verified_at = CASE WHEN phone = $2 THEN phone_verified_at END,
+    email_verified_at = CASE WHEN lower(email) = lower($5) THEN email_verified_at END
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion
 `
 
 type UpdateUserParams struct {
@@ -187,6 +770,232 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Status,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2,
+    password_changed_at = $3
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion
+`
+
+type UpdateUserPasswordParams struct {
+	ID                int64              `json:"id"`
+	HashedPassword    string             `json:"hashed_password"`
+	PasswordChangedAt pgtype.Timestamptz `json:"password_changed_at"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserPassword, arg.ID, arg.HashedPassword, arg.PasswordChangedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Gender,
+		&i.Age,
+		&i.Email,
+		&i.Phone,
+		&i.HashedPassword,
+		&i.Avatar,
+		&i.Status,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
+	)
+	return i, err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users
+SET role = $2
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion
+`
+
+type UpdateUserRoleParams struct {
+	ID   int64  `json:"id"`
+	Role string `json:"role"`
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Gender,
+		&i.Age,
+		&i.Email,
+		&i.Phone,
+		&i.HashedPassword,
+		&i.Avatar,
+		&i.Status,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
+	)
+	return i, err
+}
+
+const updateUserStatus = `-- name: UpdateUserStatus :one
+UPDATE users
+SET status = $2,
+    status_reason = $3,
+    status_expires_at = $4
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion
+`
+
+type UpdateUserStatusParams struct {
+	ID              int64              `json:"id"`
+	Status          string             `json:"status"`
+	StatusReason    string             `json:"status_reason"`
+	StatusExpiresAt pgtype.Timestamptz `json:"status_expires_at"`
+}
+
+func (q *Queries) UpdateUserStatus(ctx context.Context, arg UpdateUserStatusParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserStatus,
+		arg.ID,
+		arg.Status,
+		arg.StatusReason,
+		arg.StatusExpiresAt,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Gender,
+		&i.Age,
+		&i.Email,
+		&i.Phone,
+		&i.HashedPassword,
+		&i.Avatar,
+		&i.Status,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
+	)
+	return i, err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET email_verified_at = now(),
+    status = CASE WHEN status = 'pending_verification' THEN 'active' ELSE status END
+WHERE id = $1 AND email = $2 AND deleted_at IS NULL
+RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion
+`
+
+type VerifyUserEmailParams struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRow(ctx, verifyUserEmail, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Gender,
+		&i.Age,
+		&i.Email,
+		&i.Phone,
+		&i.HashedPassword,
+		&i.Avatar,
+		&i.Status,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
+	)
+	return i, err
+}
+
+const verifyUserPhone = `-- name: VerifyUserPhone :one
+UPDATE users
+SET phone_verified_at = now()
+WHERE id = $1 AND phone = $2 AND deleted_at IS NULL
+RETURNING id, username, full_name, gender, age, email, phone, hashed_password, avatar, status, password_changed_at, created_at, email_verified_at, phone_verified_at, role, deleted_at, status_reason, status_expires_at, display_username, tokens_valid_after, status_before_deletion
+`
+
+type VerifyUserPhoneParams struct {
+	ID    int64  `json:"id"`
+	Phone string `json:"phone"`
+}
+
+func (q *Queries) VerifyUserPhone(ctx context.Context, arg VerifyUserPhoneParams) (User, error) {
+	row := q.db.QueryRow(ctx, verifyUserPhone, arg.ID, arg.Phone)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Gender,
+		&i.Age,
+		&i.Email,
+		&i.Phone,
+		&i.HashedPassword,
+		&i.Avatar,
+		&i.Status,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.Role,
+		&i.DeletedAt,
+		&i.StatusReason,
+		&i.StatusExpiresAt,
+		&i.DisplayUsername,
+		&i.TokensValidAfter,
+		&i.StatusBeforeDeletion,
 	)
 	return i, err
 }
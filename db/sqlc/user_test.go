@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/mauzec/user-api/internal/util"
 	"github.com/stretchr/testify/assert"
 )
@@ -17,14 +21,16 @@ func createAndTestRandomUser(t *testing.T) User {
 	)
 
 	assert.NoError(t, err)
+	username := util.RandomUsername()
 	args := CreateUserParams{
-		Username:       util.RandomUsername(),
-		Email:          util.RandomEmail(),
-		HashedPassword: hashedPassword,
-		FullName:       fmt.Sprintf("%s %s", util.RandomString(5), util.RandomString(5)),
-		Phone:          util.RandomPhone(),
-		Gender:         "M",
-		Age:            int32(util.RandomInt(18, 60)),
+		Username:        username,
+		Email:           util.RandomEmail(),
+		HashedPassword:  hashedPassword,
+		FullName:        fmt.Sprintf("%s %s", util.RandomString(5), util.RandomString(5)),
+		Phone:           util.RandomPhone(),
+		Gender:          "M",
+		Age:             int32(util.RandomInt(18, 60)),
+		DisplayUsername: strings.ToUpper(username[:1]) + username[1:],
 	}
 
 	ctx := context.Background()
@@ -47,6 +53,7 @@ func createAndTestRandomUser(t *testing.T) User {
 	assert.Equal(t, args.FullName, user.FullName)
 	assert.Equal(t, args.HashedPassword, user.HashedPassword)
 	assert.Equal(t, args.Phone, user.Phone)
+	assert.Equal(t, args.DisplayUsername, user.DisplayUsername)
 
 	assert.True(t, user.PasswordChangedAt.Time.IsZero())
 	assert.NotZero(t, user.CreatedAt)
@@ -90,6 +97,75 @@ func TestGetUserByUsername(t *testing.T) {
 	assert.Equal(t, user.CreatedAt, gotUser.CreatedAt)
 }
 
+func TestCreateUserUsernameNotLowercase(t *testing.T) {
+	hashedPassword, err := util.HashPassword(util.RandomString(10))
+	assert.NoError(t, err)
+
+	_, err = testQueries.CreateUser(context.Background(), CreateUserParams{
+		Username:        "Mixed" + util.RandomUsername(),
+		Email:           util.RandomEmail(),
+		HashedPassword:  hashedPassword,
+		FullName:        util.RandomString(10),
+		Phone:           util.RandomPhone(),
+		Gender:          "M",
+		Age:             30,
+		DisplayUsername: "Mixed",
+	})
+	var pgErr *pgconn.PgError
+	assert.ErrorAs(t, err, &pgErr)
+	assert.Equal(t, "users_username_lowercase", pgErr.ConstraintName)
+}
+
+func TestGetUserByEmail(t *testing.T) {
+	user := createAndTestRandomUser(t)
+
+	gotUser, err := testQueries.GetUserByEmail(context.Background(), strings.ToUpper(user.Email))
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, gotUser.ID)
+
+	// emails are unique ignoring case
+	hashedPassword, err := util.HashPassword(util.RandomString(10))
+	assert.NoError(t, err)
+	_, err = testQueries.CreateUser(context.Background(), CreateUserParams{
+		Username:       util.RandomUsername(),
+		Email:          strings.ToUpper(user.Email),
+		HashedPassword: hashedPassword,
+		FullName:       util.RandomString(10),
+		Phone:          util.RandomPhone(),
+		Gender:         "M",
+		Age:            30,
+	})
+	var pgErr *pgconn.PgError
+	assert.ErrorAs(t, err, &pgErr)
+	assert.Equal(t, "23505", pgErr.Code)
+
+	_, err = testQueries.GetUserByEmail(context.Background(), util.RandomEmail())
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestListUsersByPhone(t *testing.T) {
+	user1 := createAndTestRandomUser(t)
+
+	users, err := testQueries.ListUsersByPhone(context.Background(), user1.Phone)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, user1.ID, users[0].ID)
+
+	user2 := createAndTestRandomUser(t)
+	_, err = testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		ID:       user2.ID,
+		FullName: user2.FullName,
+		Gender:   user2.Gender,
+		Email:    user2.Email,
+		Phone:    user1.Phone,
+	})
+	assert.NoError(t, err)
+
+	users, err = testQueries.ListUsersByPhone(context.Background(), user1.Phone)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+}
+
 func TestUpdateUser(t *testing.T) {
 	var user User
 
@@ -100,7 +176,7 @@ func TestUpdateUser(t *testing.T) {
 	t.Run("updating username", func(t *testing.T) {
 		wantArgs := UpdateUserParams{
 			ID:       user.ID,
-			Email:    "t@t.com",
+			Email:    util.RandomEmail(),
 			FullName: "Ka Ma",
 			Gender:   "M",
 			Phone:    "+123413424",
@@ -128,24 +204,339 @@ func TestUpdateUser(t *testing.T) {
 
 }
 
-func TestDeleteUser(t *testing.T) {
-	var user User
+func TestUpdateUserPassword(t *testing.T) {
+	user := createAndTestRandomUser(t)
 
-	t.Run("creating new user", func(t *testing.T) {
-		user = createAndTestRandomUser(t)
+	hashedPassword, err := util.HashPassword(util.RandomString(10))
+	assert.NoError(t, err)
+	changedAt := time.Now()
+
+	updated, err := testQueries.UpdateUserPassword(context.Background(), UpdateUserPasswordParams{
+		ID:                user.ID,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: pgtype.Timestamptz{Time: changedAt, Valid: true},
 	})
+	assert.NoError(t, err)
 
-	t.Run("deleting user", func(t *testing.T) {
-		err := testQueries.DeleteUserByID(context.Background(), user.ID)
-		if !assert.NoError(t, err) {
-			log.Fatal(fmt.Errorf("error deleting user with ID: %d, error: %v", user.ID, err))
+	assert.Equal(t, user.ID, updated.ID)
+	assert.Equal(t, hashedPassword, updated.HashedPassword)
+	assert.WithinDuration(t, changedAt, updated.PasswordChangedAt.Time, time.Second)
+
+	state, err := testQueries.GetUserAuthState(context.Background(), user.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, updated.PasswordChangedAt, state.PasswordChangedAt)
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	assert.False(t, user.TokensValidAfter.Time.After(user.CreatedAt.Time))
+
+	revokedAt := time.Now()
+	updated, err := testQueries.RevokeUserTokens(context.Background(), RevokeUserTokensParams{
+		Username:         user.Username,
+		TokensValidAfter: pgtype.Timestamptz{Time: revokedAt, Valid: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, updated.ID)
+	assert.WithinDuration(t, revokedAt, updated.TokensValidAfter.Time, time.Second)
+
+	state, err := testQueries.GetUserAuthState(context.Background(), user.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, updated.TokensValidAfter, state.TokensValidAfter)
+}
+
+func TestRehashUserPassword(t *testing.T) {
+	user := createAndTestRandomUser(t)
+
+	hashedPassword, err := util.HashPassword(util.RandomString(10))
+	assert.NoError(t, err)
+
+	// the hash is only replaced while it is still the old one
+	err = testQueries.RehashUserPassword(context.Background(), RehashUserPasswordParams{
+		ID:                user.ID,
+		NewHashedPassword: hashedPassword,
+		OldHashedPassword: "outdated",
+	})
+	assert.NoError(t, err)
+	got, err := testQueries.GetUserByID(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user.HashedPassword, got.HashedPassword)
+
+	err = testQueries.RehashUserPassword(context.Background(), RehashUserPasswordParams{
+		ID:                user.ID,
+		NewHashedPassword: hashedPassword,
+		OldHashedPassword: user.HashedPassword,
+	})
+	assert.NoError(t, err)
+	got, err = testQueries.GetUserByID(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, hashedPassword, got.HashedPassword)
+	assert.Equal(t, user.PasswordChangedAt, got.PasswordChangedAt)
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	assert.Equal(t, util.RoleUser, user.Role)
+
+	updated, err := testQueries.UpdateUserRole(context.Background(), UpdateUserRoleParams{
+		ID:   user.ID,
+		Role: util.RoleAdmin,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, util.RoleAdmin, updated.Role)
+
+	// the check constraint rejects unknown roles
+	_, err = testQueries.UpdateUserRole(context.Background(), UpdateUserRoleParams{
+		ID:   user.ID,
+		Role: "root",
+	})
+	assert.Error(t, err)
+}
+
+func TestUpdateUserStatus(t *testing.T) {
+	user := createAndTestRandomUser(t)
+
+	expiresAt := time.Now().Add(time.Hour)
+	updated, err := testQueries.UpdateUserStatus(context.Background(), UpdateUserStatusParams{
+		ID:              user.ID,
+		Status:          "suspended",
+		StatusReason:    "spam",
+		StatusExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, updated.ID)
+	assert.Equal(t, "suspended", updated.Status)
+	assert.Equal(t, "spam", updated.StatusReason)
+	assert.WithinDuration(t, expiresAt, updated.StatusExpiresAt.Time, time.Second)
+
+	state, err := testQueries.GetUserAuthState(context.Background(), user.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, "suspended", state.Status)
+	assert.Equal(t, updated.StatusExpiresAt, state.StatusExpiresAt)
+
+	// the check constraint rejects unknown statuses
+	_, err = testQueries.UpdateUserStatus(context.Background(), UpdateUserStatusParams{
+		ID:     user.ID,
+		Status: "frozen",
+	})
+	assert.Error(t, err)
+}
+
+func TestListUsers(t *testing.T) {
+	var created []User
+	for i := 0; i < 3; i++ {
+		created = append(created, createAndTestRandomUser(t))
+	}
+	from := created[0].CreatedAt
+
+	// pages in both directions hold the created users in order, without repeats
+	for _, desc := range []bool{false, true} {
+		args := ListUsersParams{
+			Gender:      pgtype.Text{String: "M", Valid: true},
+			CreatedFrom: from,
+			PageLimit:   2,
+		}
+
+		var got []User
+		for {
+			var page []User
+			var err error
+			if desc {
+				page, err = testQueries.ListUsersDesc(context.Background(), ListUsersDescParams(args))
+			} else {
+				page, err = testQueries.ListUsers(context.Background(), args)
+			}
+			assert.NoError(t, err)
+			got = append(got, page...)
+			if len(page) < int(args.PageLimit) {
+				break
+			}
+			last := page[len(page)-1]
+			args.AfterCreatedAt = last.CreatedAt
+			args.AfterID = pgtype.Int8{Int64: last.ID, Valid: true}
+		}
+
+		seen := make(map[int64]bool)
+		for i, user := range got {
+			assert.False(t, seen[user.ID])
+			seen[user.ID] = true
+			assert.False(t, user.CreatedAt.Time.Before(from.Time))
+			if i > 0 {
+				prev := got[i-1]
+				if desc {
+					assert.False(t, user.CreatedAt.Time.After(prev.CreatedAt.Time))
+				} else {
+					assert.False(t, user.CreatedAt.Time.Before(prev.CreatedAt.Time))
+				}
+			}
+		}
+		for _, user := range created {
+			assert.True(t, seen[user.ID])
+		}
+	}
+}
+
+func TestListUsersByCurrentStatus(t *testing.T) {
+	expired := createAndTestRandomUser(t)
+	suspended := createAndTestRandomUser(t)
+
+	for user, expiresAt := range map[int64]time.Time{
+		expired.ID:   time.Now().Add(-time.Minute),
+		suspended.ID: time.Now().Add(time.Hour),
+	} {
+		_, err := testQueries.UpdateUserStatus(context.Background(), UpdateUserStatusParams{
+			ID:              user,
+			Status:          "suspended",
+			StatusExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		})
+		assert.NoError(t, err)
+	}
+
+	list := func(status string) map[int64]bool {
+		users, err := testQueries.ListUsers(context.Background(), ListUsersParams{
+			Status:      pgtype.Text{String: status, Valid: true},
+			CreatedFrom: expired.CreatedAt,
+			PageLimit:   100,
+		})
+		assert.NoError(t, err)
+
+		ids := make(map[int64]bool)
+		for _, user := range users {
+			ids[user.ID] = true
 		}
+		return ids
+	}
+
+	// an expired suspension is listed under the status it lifted to
+	ids := list("suspended")
+	assert.True(t, ids[suspended.ID])
+	assert.False(t, ids[expired.ID])
+
+	lifted := "active"
+	if !expired.EmailVerifiedAt.Valid {
+		lifted = "pending_verification"
+	}
+	ids = list(lifted)
+	assert.True(t, ids[expired.ID])
+	assert.False(t, ids[suspended.ID])
+}
+
+func TestSearchUsers(t *testing.T) {
+	user := createAndTestRandomUser(t)
+
+	// a part of the email matches as a substring
+	users, err := testQueries.SearchUsers(context.Background(), SearchUsersParams{
+		Pattern:   "%" + user.Email[:len(user.Email)-2] + "%",
+		Query:     user.Email[:len(user.Email)-2],
+		PageLimit: 10,
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, users)
+	assert.Equal(t, user.ID, users[0].ID)
+
+	// a misspelled username matches by similarity
+	misspelled := user.Username[:len(user.Username)-1] + "0"
+	users, err = testQueries.SearchUsers(context.Background(), SearchUsersParams{
+		Pattern:   "%" + misspelled + "%",
+		Query:     misspelled,
+		PageLimit: 10,
 	})
+	assert.NoError(t, err)
+	found := false
+	for _, u := range users {
+		found = found || u.ID == user.ID
+	}
+	assert.True(t, found)
+}
+
+func TestSoftDeleteUser(t *testing.T) {
+	user := createAndTestRandomUser(t)
+
+	deleted, err := testQueries.SoftDeleteUser(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "deleted", deleted.Status)
+	assert.Equal(t, user.Status, deleted.StatusBeforeDeletion)
+	assert.WithinDuration(t, time.Now(), deleted.DeletedAt.Time, time.Second)
+
+	// deleted users are hidden from the usual queries
+	_, err = testQueries.GetUserByID(context.Background(), user.ID)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = testQueries.GetUserByUsername(context.Background(), user.Username)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = testQueries.GetUserAuthState(context.Background(), user.Username)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = testQueries.SoftDeleteUser(context.Background(), user.ID)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	got, err := testQueries.GetDeletedUserByUsername(context.Background(), user.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+
+	// too late to restore
+	_, err = testQueries.RestoreUser(context.Background(), RestoreUserParams{
+		ID:           user.ID,
+		DeletedAfter: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+	})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	restored, err := testQueries.RestoreUser(context.Background(), RestoreUserParams{
+		ID:           user.ID,
+		DeletedAfter: pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "pending_verification", restored.Status)
+	assert.Empty(t, restored.StatusBeforeDeletion)
+	assert.False(t, restored.DeletedAt.Valid)
+
+	_, err = testQueries.GetUserByID(context.Background(), user.ID)
+	assert.NoError(t, err)
+}
 
-	t.Run("getting deleted user", func(t *testing.T) {
-		newUser, err := testQueries.GetUserByID(context.Background(), user.ID)
-		assert.Error(t, err)
-		assert.EqualError(t, err, pgx.ErrNoRows.Error())
-		assert.Empty(t, newUser)
+func TestRestoreUserKeepsStatus(t *testing.T) {
+	user := createAndTestRandomUser(t)
+
+	expiresAt := time.Now().Add(time.Hour)
+	locked, err := testQueries.UpdateUserStatus(context.Background(), UpdateUserStatusParams{
+		ID:              user.ID,
+		Status:          "locked",
+		StatusReason:    "too many failed logins",
+		StatusExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	assert.NoError(t, err)
+
+	_, err = testQueries.SoftDeleteUser(context.Background(), user.ID)
+	assert.NoError(t, err)
+
+	// deleting does not lift the lock
+	restored, err := testQueries.RestoreUser(context.Background(), RestoreUserParams{
+		ID:           user.ID,
+		DeletedAfter: pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, locked.Status, restored.Status)
+	assert.Equal(t, locked.StatusReason, restored.StatusReason)
+	assert.Equal(t, locked.StatusExpiresAt, restored.StatusExpiresAt)
+}
+
+func TestPurgeDeletedUsersTx(t *testing.T) {
+	user := createAndTestRandomUser(t)
+	session := createAndTestRandomSession(t, user)
+	kept := createAndTestRandomUser(t)
+
+	_, err := testQueries.SoftDeleteUser(context.Background(), user.ID)
+	assert.NoError(t, err)
+
+	result, err := testStore.PurgeDeletedUsersTx(context.Background(), PurgeDeletedUsersTxParams{
+		DeletedBefore: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
 	})
+	assert.NoError(t, err)
+	assert.Contains(t, result.Usernames, user.Username)
+	assert.NotContains(t, result.Usernames, kept.Username)
+
+	_, err = testQueries.GetDeletedUserByUsername(context.Background(), user.Username)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = testQueries.GetSession(context.Background(), session.ID)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = testQueries.GetUserByID(context.Background(), kept.ID)
+	assert.NoError(t, err)
 }
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/util"
+)
+
+var ErrChangeOwnAccount = errors.New("admins can not change the role or status of their own account")
+
+// getManagedUser loads the user of the uri for an admin action. Admins can not
+// manage their own accounts so that the last admin can not lock everyone out.
+// On failure the response is written and false is returned.
+func (server *Server) getManagedUser(ctx *gin.Context) (db.User, bool) {
+	var uri getUserUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return db.User{}, false
+	}
+
+	payload, err := getAuthPayload(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return db.User{}, false
+	}
+	if payload.Username == uri.Username {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrChangeOwnAccount))
+		return db.User{}, false
+	}
+
+	user, err := server.store.GetUserByUsername(ctx, uri.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ErrNotFound))
+			return db.User{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return db.User{}, false
+	}
+	return user, true
+}
+
+type userStatusResponse struct {
+	userResponse
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusExpiresAt *time.Time `json:"status_expires_at,omitempty"`
+}
+
+func newUserStatusResponse(user db.User) userStatusResponse {
+	resp := userStatusResponse{userResponse: newUserResponse(user)}
+	// an expired restriction is no longer in force
+	if isRestrictedStatus(resp.Status) {
+		resp.StatusReason = user.StatusReason
+		if user.StatusExpiresAt.Valid {
+			resp.StatusExpiresAt = &user.StatusExpiresAt.Time
+		}
+	}
+	return resp
+}
+
+type updateUserStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=active suspended banned locked"`
+	Reason string `json:"reason" binding:"max=256"`
+	// restrictions are permanent by default
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// updateUserStatus moves the user to another status, see userStatusTransitions.
+func (server *Server) updateUserStatus(ctx *gin.Context) {
+	var req updateUserStatusRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	user, ok := server.getManagedUser(ctx)
+	if !ok {
+		return
+	}
+	server.setUserStatus(ctx, user, req)
+}
+
+// setUserStatus checks the transition and the request, then changes the status
+// of the user. Restricted users lose all of their sessions right away.
+// On failure the response is written.
+func (server *Server) setUserStatus(ctx *gin.Context, user db.User, req updateUserStatusRequest) {
+	if req.ExpiresAt != nil {
+		if !isRestrictedStatus(req.Status) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("only suspensions, bans and locks can expire")))
+			return
+		}
+		if !req.ExpiresAt.After(time.Now()) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidExpiresAt))
+			return
+		}
+	}
+
+	status := userCurrentStatus(user)
+	if !canChangeStatus(status, req.Status) {
+		ctx.JSON(http.StatusConflict, errorResponse(newStatusTransitionError(status, req.Status)))
+		return
+	}
+
+	args := db.UpdateUserStatusParams{
+		ID:           user.ID,
+		Status:       req.Status,
+		StatusReason: req.Reason,
+	}
+	if req.ExpiresAt != nil {
+		args.StatusExpiresAt = pgtype.Timestamptz{Time: *req.ExpiresAt, Valid: true}
+	}
+	updated, err := server.store.UpdateUserStatus(ctx, args)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ErrNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	if isRestrictedStatus(req.Status) {
+		revoked, err := server.store.RevokeUserSessions(ctx, user.Username)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+			return
+		}
+		for _, r := range revoked {
+			server.denylist.add(r.ID, r.ExpiresAt.Time)
+		}
+	}
+	server.userStates.set(updated)
+
+	ctx.JSON(http.StatusOK, newUserStatusResponse(updated))
+}
+
+type suspendUserRequest struct {
+	Reason string `json:"reason" binding:"max=256"`
+	// permanent by default
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// suspendUser stops the user from logging in and revokes all of their sessions.
+// The body with the reason and expiry is optional.
+func (server *Server) suspendUser(ctx *gin.Context) {
+	var req suspendUserRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+			return
+		}
+	}
+
+	user, ok := server.getManagedUser(ctx)
+	if !ok {
+		return
+	}
+	server.setUserStatus(ctx, user, updateUserStatusRequest{
+		Status:    userStatusSuspended,
+		Reason:    req.Reason,
+		ExpiresAt: req.ExpiresAt,
+	})
+}
+
+// unsuspendUser lets a suspended user log in again.
+func (server *Server) unsuspendUser(ctx *gin.Context) {
+	user, ok := server.getManagedUser(ctx)
+	if !ok {
+		return
+	}
+	if userCurrentStatus(user) != userStatusSuspended {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("user is not suspended")))
+		return
+	}
+	server.setUserStatus(ctx, user, updateUserStatusRequest{Status: userStatusActive})
+}
+
+type updateUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// updateUserRole sets the role of the user. Tokens carry the role they were
+// issued with, so a change ends all sessions of the user and rejects the
+// tokens issued before, the new role takes effect with the next login.
+// API keys take the role of their user on every request.
+func (server *Server) updateUserRole(ctx *gin.Context) {
+	var req updateUserRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+	if !util.IsSupportedRole(req.Role) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("unsupported role")))
+		return
+	}
+
+	user, ok := server.getManagedUser(ctx)
+	if !ok {
+		return
+	}
+
+	updated, err := server.store.UpdateUserRole(ctx, db.UpdateUserRoleParams{
+		ID:   user.ID,
+		Role: req.Role,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	if updated.Role != user.Role {
+		revoked, err := server.store.RevokeUserSessions(ctx, user.Username)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+			return
+		}
+		for _, r := range revoked {
+			server.denylist.add(r.ID, r.ExpiresAt.Time)
+		}
+
+		updated, err = server.store.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+			Username:         user.Username,
+			TokensValidAfter: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+			return
+		}
+		server.userStates.set(updated)
+	}
+	ctx.JSON(http.StatusOK, newUserResponse(updated))
+}
@@ -0,0 +1,489 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/token"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSuspendUserAPI(t *testing.T) {
+	user := randomUser()
+	admin := randomUser()
+	admin.Role = util.RoleAdmin
+
+	testCases := []struct {
+		name          string
+		username      string
+		setupAuth     func(t *testing.T, req *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addRoleAuthHeader(t, req, tokenMaker, authTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				suspended := user
+				suspended.Status = userStatusSuspended
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Eq(db.UpdateUserStatusParams{
+						ID:     user.ID,
+						Status: userStatusSuspended,
+					})).
+					Times(1).
+					Return(suspended, nil)
+				store.EXPECT().
+					RevokeUserSessions(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return([]db.RevokedToken{{
+						ID:        uuid.New(),
+						Username:  user.Username,
+						ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+					}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp userResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Equal(t, userStatusSuspended, resp.Status)
+			},
+		},
+		{
+			name:     "NotAdmin",
+			username: user.Username,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addRoleAuthHeader(t, req, tokenMaker, authTypeBearer, admin.Username, util.RoleModerator, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "NoAuthorization",
+			username: user.Username,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "OwnAccount",
+			username: admin.Username,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addRoleAuthHeader(t, req, tokenMaker, authTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "UserNotFound",
+			username: user.Username,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addRoleAuthHeader(t, req, tokenMaker, authTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthChecks(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/admin/users/%s/suspend", tc.username)
+			req, err := http.NewRequest(http.MethodPost, url, nil)
+			assert.NoError(t, err)
+
+			tc.setupAuth(t, req, server.tokenMaker)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateUserRoleAPI(t *testing.T) {
+	user := randomUser()
+	admin := randomUser()
+	admin.Role = util.RoleAdmin
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"role": util.RoleModerator},
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.Role = util.RoleModerator
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserRole(gomock.Any(), gomock.Eq(db.UpdateUserRoleParams{
+						ID:   user.ID,
+						Role: util.RoleModerator,
+					})).
+					Times(1).
+					Return(updated, nil)
+				// tokens carry the old role, so they stop working
+				store.EXPECT().
+					RevokeUserSessions(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return([]db.RevokedToken{}, nil)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.RevokeUserTokensParams) (db.User, error) {
+						assert.Equal(t, user.Username, arg.Username)
+						assert.WithinDuration(t, time.Now(), arg.TokensValidAfter.Time, time.Second)
+						revoked := updated
+						revoked.TokensValidAfter = arg.TokensValidAfter
+						return revoked, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp userResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Equal(t, util.RoleModerator, resp.Role)
+			},
+		},
+		{
+			name: "SameRole",
+			body: gin.H{"role": user.Role},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserRole(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "RevokeUserTokensError",
+			body: gin.H{"role": util.RoleModerator},
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.Role = util.RoleModerator
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserRole(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(updated, nil)
+				store.EXPECT().
+					RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.RevokedToken{}, nil)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "UnsupportedRole",
+			body: gin.H{"role": "root"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserRole(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"role": util.RoleAdmin},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserRole(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthChecks(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			url := fmt.Sprintf("/admin/users/%s/role", user.Username)
+			req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			addRoleAuthHeader(t, req, server.tokenMaker, authTypeBearer, admin.Username, admin.Role, time.Minute)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateUserStatusAPI(t *testing.T) {
+	user := randomUser()
+	admin := randomUser()
+	admin.Role = util.RoleAdmin
+	expiresAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Microsecond)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Ban",
+			body: gin.H{"status": userStatusBanned, "reason": "spam", "expires_at": expiresAt},
+			buildStubs: func(store *mockdb.MockStore) {
+				banned := user
+				banned.Status = userStatusBanned
+				banned.StatusReason = "spam"
+				banned.StatusExpiresAt = pgtype.Timestamptz{Time: expiresAt, Valid: true}
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Eq(db.UpdateUserStatusParams{
+						ID:              user.ID,
+						Status:          userStatusBanned,
+						StatusReason:    "spam",
+						StatusExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+					})).
+					Times(1).
+					Return(banned, nil)
+				store.EXPECT().
+					RevokeUserSessions(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return([]db.RevokedToken{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp userStatusResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Equal(t, userStatusBanned, resp.Status)
+				assert.Equal(t, "spam", resp.StatusReason)
+				assert.True(t, expiresAt.Equal(*resp.StatusExpiresAt))
+			},
+		},
+		{
+			name: "Activate",
+			body: gin.H{"status": userStatusActive},
+			buildStubs: func(store *mockdb.MockStore) {
+				locked := user
+				locked.Status = userStatusLocked
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(locked, nil)
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Eq(db.UpdateUserStatusParams{
+						ID:     user.ID,
+						Status: userStatusActive,
+					})).
+					Times(1).
+					Return(user, nil)
+				// lifting a restriction keeps the sessions as they are
+				store.EXPECT().
+					RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotAllowedTransition",
+			body: gin.H{"status": userStatusLocked},
+			buildStubs: func(store *mockdb.MockStore) {
+				banned := user
+				banned.Status = userStatusBanned
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(banned, nil)
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "ExpiringActive",
+			body: gin.H{"status": userStatusActive, "expires_at": expiresAt},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "ExpiresInPast",
+			body: gin.H{"status": userStatusSuspended, "expires_at": time.Now().Add(-time.Hour)},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "UnsupportedStatus",
+			body: gin.H{"status": userStatusDeleted},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthChecks(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			url := fmt.Sprintf("/admin/users/%s/status", user.Username)
+			req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			addRoleAuthHeader(t, req, server.tokenMaker, authTypeBearer, admin.Username, admin.Role, time.Minute)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
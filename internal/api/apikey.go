@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/token"
+	"github.com/mauzec/user-api/internal/util"
+)
+
+const (
+	// apiKeyPrefix starts every key, so leaked keys are easy to find in code and logs
+	apiKeyPrefix = "uak_"
+	// apiKeySize is the number of random bytes in an API key
+	apiKeySize = 32
+	// the number of leading characters of a key stored in the clear to tell keys apart
+	apiKeyVisibleSize = len(apiKeyPrefix) + 8
+)
+
+var (
+	ErrInvalidAPIKey    = errors.New("api key is invalid, revoked or expired")
+	ErrDuplicateAPIKey  = errors.New("an api key with this name already exists")
+	ErrInvalidExpiresAt = errors.New("expires_at must be in the future")
+)
+
+// resolveAPIKey is the apiKeyResolver of the server. The payload gets the ID
+// of the key and its scopes, limited by the current role of the owner.
+func (server *Server) resolveAPIKey(ctx context.Context, key string) (*token.Payload, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := server.store.GetApiKeyByHash(ctx, util.HashToken(key))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		log.Println("unable to get api key:", err)
+		return nil, ErrInternalServerError
+	}
+
+	user, err := server.store.GetUserByID(ctx, apiKey.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		log.Println("unable to get api key owner:", err)
+		return nil, ErrInternalServerError
+	}
+	if err = statusError(userCurrentStatus(user)); err != nil {
+		return nil, err
+	}
+
+	// the usage time is only informative, so a failure does not block the request
+	if err = server.store.TouchApiKey(ctx, apiKey.ID); err != nil {
+		log.Println("unable to update api key usage time:", err)
+	}
+
+	scopes := util.RoleScopes(user.Role)
+	if len(apiKey.Scopes) > 0 {
+		scopes = util.FilterScopes(user.Role, apiKey.Scopes)
+	}
+
+	// ExpiredAt is zero for keys without expiry
+	return &token.Payload{
+		ID:        apiKey.ID,
+		Type:      token.TypeAccess,
+		Username:  user.Username,
+		Role:      user.Role,
+		Scopes:    scopes,
+		IssuedAt:  apiKey.CreatedAt.Time,
+		ExpiredAt: apiKey.ExpiresAt.Time,
+	}, nil
+}
+
+type apiKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newAPIKeyResponse(apiKey db.ApiKey) apiKeyResponse {
+	resp := apiKeyResponse{
+		ID:        apiKey.ID,
+		Name:      apiKey.Name,
+		Prefix:    apiKey.Prefix,
+		Scopes:    apiKey.Scopes,
+		CreatedAt: apiKey.CreatedAt.Time,
+	}
+	if apiKey.ExpiresAt.Valid {
+		resp.ExpiresAt = &apiKey.ExpiresAt.Time
+	}
+	if apiKey.LastUsedAt.Valid {
+		resp.LastUsedAt = &apiKey.LastUsedAt.Time
+	}
+	return resp
+}
+
+type createAPIKeyRequest struct {
+	Name string `json:"name" binding:"required,max=64"`
+	// all scopes of the role by default
+	Scopes []string `json:"scopes"`
+	// no expiry by default
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type createAPIKeyResponse struct {
+	// Key is shown only once, only its hash is stored
+	Key string `json:"key"`
+	apiKeyResponse
+}
+
+func (server *Server) createAPIKey(ctx *gin.Context) {
+	var req createAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidExpiresAt))
+		return
+	}
+
+	user, ok := server.getOwnUser(ctx)
+	if !ok {
+		return
+	}
+	if !util.AllowedScopes(user.Role, req.Scopes) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidScope))
+		return
+	}
+
+	secret, err := util.NewSecureToken(apiKeySize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	key := apiKeyPrefix + secret
+
+	args := db.CreateApiKeyParams{
+		ID:      uuid.New(),
+		UserID:  user.ID,
+		Name:    req.Name,
+		Prefix:  key[:apiKeyVisibleSize],
+		KeyHash: util.HashToken(key),
+		Scopes:  util.FilterScopes(user.Role, req.Scopes),
+	}
+	if req.ExpiresAt != nil {
+		args.ExpiresAt = pgtype.Timestamptz{Time: *req.ExpiresAt, Valid: true}
+	}
+
+	apiKey, err := server.store.CreateApiKey(ctx, args)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			ctx.JSON(http.StatusConflict, errorResponse(ErrDuplicateAPIKey))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, createAPIKeyResponse{
+		Key:            key,
+		apiKeyResponse: newAPIKeyResponse(apiKey),
+	})
+}
+
+func (server *Server) listAPIKeys(ctx *gin.Context) {
+	user, ok := server.getOwnUser(ctx)
+	if !ok {
+		return
+	}
+
+	apiKeys, err := server.store.ListApiKeys(ctx, user.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	resp := make([]apiKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		resp = append(resp, newAPIKeyResponse(apiKey))
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type apiKeyUri struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+// revokeAPIKey stops the key from working right away.
+func (server *Server) revokeAPIKey(ctx *gin.Context) {
+	var uri apiKeyUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	user, ok := server.getOwnUser(ctx)
+	if !ok {
+		return
+	}
+
+	_, err := server.store.RevokeApiKey(ctx, db.RevokeApiKeyParams{
+		ID:     uuid.MustParse(uri.ID),
+		UserID: user.ID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ErrNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,487 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/token"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func randomAPIKey(t *testing.T, user db.User, scopes []string) (db.ApiKey, string) {
+	secret, err := util.NewSecureToken(apiKeySize)
+	assert.NoError(t, err)
+	key := apiKeyPrefix + secret
+
+	return db.ApiKey{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Name:      util.RandomString(8),
+		Prefix:    key[:apiKeyVisibleSize],
+		KeyHash:   util.HashToken(key),
+		Scopes:    scopes,
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}, key
+}
+
+func addAPIKeyHeader(req *http.Request, key string) {
+	req.Header.Set(authHeaderKey, fmt.Sprintf("ApiKey %s", key))
+}
+
+func TestCreateAPIKeyAPI(t *testing.T) {
+	user := randomUser()
+	_, otherKey := randomAPIKey(t, user, nil)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setupAuth     func(t *testing.T, req *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"name": "ci", "scopes": []string{util.ScopeUsersRead}},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateApiKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateApiKeyParams) (db.ApiKey, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						assert.Equal(t, "ci", arg.Name)
+						assert.Equal(t, []string{util.ScopeUsersRead}, arg.Scopes)
+						assert.False(t, arg.ExpiresAt.Valid)
+						return db.ApiKey{
+							ID:      arg.ID,
+							UserID:  arg.UserID,
+							Name:    arg.Name,
+							Prefix:  arg.Prefix,
+							KeyHash: arg.KeyHash,
+							Scopes:  arg.Scopes,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp createAPIKeyResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.True(t, strings.HasPrefix(resp.Key, apiKeyPrefix))
+				assert.Equal(t, resp.Key[:apiKeyVisibleSize], resp.Prefix)
+				assert.Equal(t, "ci", resp.Name)
+				assert.Nil(t, resp.ExpiresAt)
+			},
+		},
+		{
+			name: "ScopeNotAllowed",
+			body: gin.H{"name": "ci", "scopes": []string{util.ScopeUsersAdmin}},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateApiKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "ExpiresInPast",
+			body: gin.H{"name": "ci", "expires_at": time.Now().Add(-time.Hour)},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateApiKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "DuplicateName",
+			body: gin.H{"name": "ci"},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateApiKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApiKey{}, &pgconn.PgError{Code: "23505"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "WithAPIKey",
+			body: gin.H{"name": "ci"},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAPIKeyHeader(req, otherKey)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				key, _ := randomAPIKey(t, user, nil)
+				store.EXPECT().
+					GetApiKeyByHash(gomock.Any(), gomock.Eq(util.HashToken(otherKey))).
+					Times(1).
+					Return(key, nil)
+				store.EXPECT().
+					GetUserByID(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					TouchApiKey(gomock.Any(), gomock.Any()).
+					AnyTimes()
+				store.EXPECT().
+					CreateApiKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthChecks(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			url := fmt.Sprintf("/users/%s/api_keys", user.Username)
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			tc.setupAuth(t, req, server.tokenMaker)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	user := randomUser()
+	key, plainKey := randomAPIKey(t, user, nil)
+	readOnlyKey, plainReadOnlyKey := randomAPIKey(t, user, []string{util.ScopeUsersRead})
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		body       gin.H
+		buildStubs func(store *mockdb.MockStore)
+		status     int
+	}{
+		{
+			name:   "OK",
+			method: http.MethodGet,
+			path:   "/users/" + user.Username,
+			key:    plainKey,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKeyByHash(gomock.Any(), gomock.Eq(key.KeyHash)).
+					Times(1).
+					Return(key, nil)
+				store.EXPECT().
+					GetUserByID(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					TouchApiKey(gomock.Any(), gomock.Eq(key.ID)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "UnknownKey",
+			method: http.MethodGet,
+			path:   "/users/" + user.Username,
+			key:    plainKey,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKeyByHash(gomock.Any(), gomock.Eq(key.KeyHash)).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "NoPrefix",
+			method: http.MethodGet,
+			path:   "/users/" + user.Username,
+			key:    "not-a-key",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKeyByHash(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "SuspendedOwner",
+			method: http.MethodGet,
+			path:   "/users/" + user.Username,
+			key:    plainKey,
+			buildStubs: func(store *mockdb.MockStore) {
+				suspended := user
+				suspended.Status = userStatusSuspended
+				store.EXPECT().
+					GetApiKeyByHash(gomock.Any(), gomock.Eq(key.KeyHash)).
+					Times(1).
+					Return(key, nil)
+				store.EXPECT().
+					GetUserByID(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(suspended, nil)
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "InternalError",
+			method: http.MethodGet,
+			path:   "/users/" + user.Username,
+			key:    plainKey,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKeyByHash(gomock.Any(), gomock.Eq(key.KeyHash)).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrConnDone)
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "MissingScope",
+			method: http.MethodPost,
+			path:   "/users/" + user.Username,
+			key:    plainReadOnlyKey,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKeyByHash(gomock.Any(), gomock.Eq(readOnlyKey.KeyHash)).
+					Times(1).
+					Return(readOnlyKey, nil)
+				store.EXPECT().
+					GetUserByID(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					TouchApiKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "BearerOnlyRoute",
+			method: http.MethodPost,
+			path:   "/users/logout",
+			key:    plainKey,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKeyByHash(gomock.Any(), gomock.Eq(key.KeyHash)).
+					Times(1).
+					Return(key, nil)
+				store.EXPECT().
+					GetUserByID(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					TouchApiKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					RevokeToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			// a changed email could be used to reset the password
+			name:   "UpdateUser",
+			method: http.MethodPost,
+			path:   "/users/" + user.Username,
+			key:    plainKey,
+			body:   gin.H{"email": util.RandomEmail()},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKeyByHash(gomock.Any(), gomock.Eq(key.KeyHash)).
+					Times(1).
+					Return(key, nil)
+				store.EXPECT().
+					GetUserByID(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					TouchApiKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			status: http.StatusForbidden,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			reqBody := tc.body
+			if reqBody == nil {
+				reqBody = gin.H{"fullname": util.RandomString(10)}
+			}
+			body, err := json.Marshal(reqBody)
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(tc.method, tc.path, bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			addAPIKeyHeader(req, tc.key)
+			server.router.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.status, recorder.Code)
+		})
+	}
+}
+
+func TestRevokeAPIKeyAPI(t *testing.T) {
+	user := randomUser()
+	key, _ := randomAPIKey(t, user, nil)
+
+	testCases := []struct {
+		name       string
+		id         string
+		buildStubs func(store *mockdb.MockStore)
+		status     int
+	}{
+		{
+			name: "OK",
+			id:   key.ID.String(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RevokeApiKey(gomock.Any(), gomock.Eq(db.RevokeApiKeyParams{ID: key.ID, UserID: user.ID})).
+					Times(1).
+					Return(key, nil)
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name: "NotFound",
+			id:   key.ID.String(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RevokeApiKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrNoRows)
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name: "InvalidID",
+			id:   "123",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeApiKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthChecks(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/users/%s/api_keys/%s", user.Username, tc.id)
+			req, err := http.NewRequest(http.MethodDelete, url, nil)
+			assert.NoError(t, err)
+
+			addAuthHeader(t, req, server.tokenMaker, authTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.status, recorder.Code)
+		})
+	}
+}
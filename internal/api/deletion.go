@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/util"
+)
+
+// defaultDeletionGracePeriod is how long deleted users can be restored
+// before they are purged, unless set by WithDeletionGracePeriod
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+var ErrRestorePeriodOver = errors.New("the user was deleted too long ago to be restored")
+
+type deleteUserResponse struct {
+	Username  string    `json:"username"`
+	DeletedAt time.Time `json:"deleted_at"`
+	// the user can be restored until then, later it is purged
+	RestoreUntil time.Time `json:"restore_until"`
+}
+
+// deleteUser soft deletes the user and ends all of their sessions. Deleted
+// users are hidden from every query, so their remaining tokens stop working.
+// Users can delete only themselves, admins can delete anyone.
+func (server *Server) deleteUser(ctx *gin.Context) {
+	var uri getUserUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	payload, err := getAuthPayload(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	isAdmin := payload.Role == util.RoleAdmin && payload.HasScope(util.ScopeUsersAdmin)
+	if payload.Username != uri.Username && !isAdmin {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(ErrPermissionDenied))
+		return
+	}
+
+	user, err := server.store.GetUserByUsername(ctx, uri.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ErrNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	// a suspended or banned user deleting themselves would hide the
+	// restriction from moderators until the user is purged
+	if status := userCurrentStatus(user); !canChangeStatus(status, userStatusDeleted) {
+		ctx.JSON(http.StatusConflict, errorResponse(newStatusTransitionError(status, userStatusDeleted)))
+		return
+	}
+
+	deleted, err := server.store.SoftDeleteUser(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ErrNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	revoked, err := server.store.RevokeUserSessions(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	for _, r := range revoked {
+		server.denylist.add(r.ID, r.ExpiresAt.Time)
+	}
+	// the next check finds no user and rejects the access tokens left
+	server.userStates.forget(user.Username)
+
+	// other instances may still have the user cached, the denylist reaches them sooner
+	if payload.Username == user.Username {
+		if err = server.denylist.revoke(ctx, payload); err != nil {
+			log.Println("unable to revoke token of deleted user:", err)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, deleteUserResponse{
+		Username:     deleted.Username,
+		DeletedAt:    deleted.DeletedAt.Time,
+		RestoreUntil: deleted.DeletedAt.Time.Add(server.deletionGracePeriod),
+	})
+}
+
+type restoreUserRequest struct {
+	Username string `json:"username" binding:"required,alphanum"`
+	Password string `json:"password" binding:"required"`
+}
+
+// restoreUser undoes the deletion of a user within the grace period.
+// Deleted users can not log in, so the password is checked here, throttled
+// like logins. Sessions ended by the deletion stay ended, the user has to
+// log in again, and the user gets back the status they had.
+func (server *Server) restoreUser(ctx *gin.Context) {
+	var req restoreUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	username := normalizeUsername(req.Username)
+	clientIP := ctx.ClientIP()
+	wait, err := server.loginThrottle.wait(ctx, username, clientIP)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	if wait > 0 {
+		setRetryAfter(ctx, wait)
+		ctx.JSON(http.StatusTooManyRequests, errorResponse(ErrTooManyLoginAttempts))
+		return
+	}
+
+	user, err := server.store.GetDeletedUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// the answer of a wrong password after as long a check, so it
+			// does not tell which users were deleted
+			server.checkDummyPassword(req.Password)
+			if _, err = server.loginThrottle.fail(ctx, username, clientIP); err != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+				return
+			}
+			ctx.JSON(http.StatusUnauthorized, errorResponse(ErrInvalidCredentials))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	if err = server.passwordHasher.Check(user.HashedPassword, req.Password); err != nil {
+		server.failLogin(ctx, user, ErrInvalidCredentials)
+		return
+	}
+	server.loginThrottle.reset(ctx, usernameFailureKey(username), ipFailureKey(clientIP))
+
+	restored, err := server.store.RestoreUser(ctx, db.RestoreUserParams{
+		ID:           user.ID,
+		DeletedAfter: pgtype.Timestamptz{Time: time.Now().Add(-server.deletionGracePeriod), Valid: true},
+	})
+	if err != nil {
+		// the purge job has not got to the user yet
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusGone, errorResponse(ErrRestorePeriodOver))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	ctx.JSON(http.StatusOK, newUserResponse(restored))
+}
+
+// PurgeDeletedUsers permanently removes users deleted longer than the grace
+// period ago. It is meant to be run periodically and returns how many users
+// were purged.
+func (server *Server) PurgeDeletedUsers(ctx context.Context) (int, error) {
+	result, err := server.store.PurgeDeletedUsersTx(ctx, db.PurgeDeletedUsersTxParams{
+		DeletedBefore: pgtype.Timestamptz{Time: time.Now().Add(-server.deletionGracePeriod), Valid: true},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(result.Usernames), nil
+}
@@ -0,0 +1,399 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/token"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func deletedUser(user db.User) db.User {
+	user.Status = userStatusDeleted
+	user.DeletedAt = pgtype.Timestamptz{Time: time.Now().UTC().Truncate(time.Microsecond), Valid: true}
+	return user
+}
+
+func TestDeleteUserAPI(t *testing.T) {
+	user := randomUser()
+	admin := randomUser()
+	admin.Role = util.RoleAdmin
+
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, req *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					SoftDeleteUser(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(deletedUser(user), nil)
+				store.EXPECT().
+					RevokeUserSessions(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return([]db.RevokedToken{{
+						ID:        uuid.New(),
+						Username:  user.Username,
+						ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+					}}, nil)
+				// the token of the request is revoked too
+				store.EXPECT().
+					RevokeToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp deleteUserResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Equal(t, user.Username, resp.Username)
+				assert.Equal(t, defaultDeletionGracePeriod, resp.RestoreUntil.Sub(resp.DeletedAt))
+			},
+		},
+		{
+			name: "AdminDeletesUser",
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addRoleAuthHeader(t, req, tokenMaker, authTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					SoftDeleteUser(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(deletedUser(user), nil)
+				store.EXPECT().
+					RevokeUserSessions(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return([]db.RevokedToken{}, nil)
+				store.EXPECT().
+					RevokeToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "OtherUser",
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, "other", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SoftDeleteUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SoftDeleteUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "SuspendedUser",
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addRoleAuthHeader(t, req, tokenMaker, authTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				suspended := user
+				suspended.Status = userStatusSuspended
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(suspended, nil)
+				store.EXPECT().
+					SoftDeleteUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "UserNotFound",
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					SoftDeleteUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					SoftDeleteUser(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+				store.EXPECT().
+					RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthChecks(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/users/%s", user.Username)
+			req, err := http.NewRequest(http.MethodDelete, url, nil)
+			assert.NoError(t, err)
+
+			tc.setupAuth(t, req, server.tokenMaker)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRestoreUserAPI(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+	deleted := deletedUser(user)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"username": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetDeletedUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(deleted, nil)
+				store.EXPECT().
+					ResetLoginFailures(gomock.Any(), gomock.Eq([]string{
+						usernameFailureKey(user.Username),
+						ipFailureKey(""),
+					})).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					RestoreUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.RestoreUserParams) (db.User, error) {
+						assert.Equal(t, user.ID, arg.ID)
+						assert.WithinDuration(t, time.Now().Add(-defaultDeletionGracePeriod), arg.DeletedAfter.Time, time.Second)
+						return user, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assertBodyMatchUser(t, recorder.Body, user)
+			},
+		},
+		{
+			name: "WrongPassword",
+			body: gin.H{"username": user.Username, "password": "wrong" + password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetDeletedUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(deleted, nil)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(db.LoginFailure{Failures: int32(DefaultLoginThrottleParams.LockoutThreshold)}, nil)
+				// a lockout leaves the status alone
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					RestoreUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Throttled",
+			body: gin.H{"username": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Eq(usernameFailureKey(user.Username))).
+					Times(1).
+					Return(db.LoginFailure{
+						Key:          usernameFailureKey(user.Username),
+						Failures:     5,
+						LastFailedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+					}, nil)
+				store.EXPECT().
+					GetDeletedUserByUsername(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				assert.NotEmpty(t, recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name: "NotDeleted",
+			body: gin.H{"username": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetDeletedUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				// guessing users counts too
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(db.LoginFailure{Failures: 1}, nil)
+				store.EXPECT().
+					RestoreUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				// the same answer as a wrong password
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "GracePeriodOver",
+			body: gin.H{"username": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetDeletedUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(deleted, nil)
+				store.EXPECT().
+					RestoreUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusGone, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidRequest",
+			body: gin.H{"username": user.Username},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetDeletedUserByUsername(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubLoginThrottle(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/restore", bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	gracePeriod := 24 * time.Hour
+	store.EXPECT().
+		PurgeDeletedUsersTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.PurgeDeletedUsersTxParams) (db.PurgeDeletedUsersTxResult, error) {
+			assert.WithinDuration(t, time.Now().Add(-gracePeriod), arg.DeletedBefore.Time, time.Second)
+			return db.PurgeDeletedUsersTxResult{Usernames: []string{"first", "second"}}, nil
+		})
+
+	server := newTestServer(t, store, WithDeletionGracePeriod(gracePeriod))
+	purged, err := server.PurgeDeletedUsers(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+}
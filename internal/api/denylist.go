@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/token"
+)
+
+const (
+	// how often the in-memory denylist picks up tokens revoked by other instances
+	denylistSyncInterval = 10 * time.Second
+	// rows are read starting a bit before the last seen one, so that
+	// revocations committed out of order are not missed
+	denylistSyncOverlap = 5 * time.Second
+)
+
+var ErrRevokedToken = errors.New("token is revoked")
+
+// tokenDenylist keeps IDs of revoked tokens. Postgres is the source of truth,
+// the in-memory copy is refreshed every denylistSyncInterval so the check
+// does not hit the database on every request.
+type tokenDenylist struct {
+	store db.Store
+
+	mu       sync.RWMutex
+	ids      map[uuid.UUID]time.Time // token id -> token expiration
+	syncedAt time.Time
+	lastSeen time.Time
+}
+
+func newTokenDenylist(store db.Store) *tokenDenylist {
+	return &tokenDenylist{
+		store: store,
+		ids:   make(map[uuid.UUID]time.Time),
+	}
+}
+
+// revoke adds the token to the denylist. Tokens are revoked until they expire.
+func (d *tokenDenylist) revoke(ctx context.Context, payload *token.Payload) error {
+	err := d.store.RevokeToken(ctx, db.RevokeTokenParams{
+		ID:        payload.ID,
+		Username:  payload.Username,
+		ExpiresAt: pgtype.Timestamptz{Time: payload.ExpiredAt, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	d.add(payload.ID, payload.ExpiredAt)
+	return nil
+}
+
+func (d *tokenDenylist) add(id uuid.UUID, expiresAt time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.ids[id] = expiresAt
+}
+
+// check is a payloadCheck rejecting revoked tokens.
+func (d *tokenDenylist) check(ctx context.Context, payload *token.Payload) error {
+	if err := d.sync(ctx); err != nil {
+		log.Println("unable to sync token denylist:", err)
+		return ErrInternalServerError
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if _, revoked := d.ids[payload.ID]; revoked {
+		return ErrRevokedToken
+	}
+	return nil
+}
+
+func (d *tokenDenylist) sync(ctx context.Context) error {
+	d.mu.RLock()
+	due := time.Since(d.syncedAt) >= denylistSyncInterval
+	d.mu.RUnlock()
+	if !due {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// someone else could sync while we were waiting for the lock
+	if time.Since(d.syncedAt) < denylistSyncInterval {
+		return nil
+	}
+
+	since := d.lastSeen
+	if !since.IsZero() {
+		since = since.Add(-denylistSyncOverlap)
+	}
+	revoked, err := d.store.ListRevokedTokensSince(ctx, pgtype.Timestamptz{Time: since, Valid: true})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, r := range revoked {
+		d.ids[r.ID] = r.ExpiresAt.Time
+		if r.RevokedAt.Time.After(d.lastSeen) {
+			d.lastSeen = r.RevokedAt.Time
+		}
+	}
+	// expired tokens are rejected anyway, no need to remember them
+	for id, expiresAt := range d.ids {
+		if now.After(expiresAt) {
+			delete(d.ids, id)
+		}
+	}
+	d.syncedAt = now
+	return nil
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/notify"
+	"github.com/mauzec/user-api/internal/util"
+)
+
+// verificationCodeSize is the number of random bytes in an email verification code
+const verificationCodeSize = 32
+
+var (
+	ErrEmailNotVerified        = errors.New("email is not verified")
+	ErrInvalidVerificationCode = errors.New("verification code is invalid or expired")
+)
+
+func (server *Server) sendVerificationCode(ctx context.Context, user db.User, code string, expiresAt time.Time) error {
+	return server.notifier.Notify(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf(
+			"Use this code to verify the email of %s: %s\nIt expires at %s.",
+			user.Username, code, expiresAt.Format(time.RFC1123),
+		),
+	})
+}
+
+// sendSignupConflict tells the email of a private signup why no user was
+// created, see WithPrivateSignup. The signup answers the same either way,
+// so a failure to send is only logged.
+func (server *Server) sendSignupConflict(ctx context.Context, req createUserRequest, conflict error) {
+	var body string
+	switch {
+	case errors.Is(conflict, ErrEmailInUse):
+		body = "Someone tried to sign up with this email, but it already belongs to a user.\n" +
+			"If it was you, log in or reset your password. Otherwise you can ignore this email."
+	case errors.Is(conflict, ErrUsernameTaken):
+		body = fmt.Sprintf("The username %s is already taken, sign up again with another one.", req.Username)
+	default:
+		body = "Your signup could not be completed, since the user already exists."
+	}
+
+	err := server.notifier.Notify(ctx, notify.Message{
+		To:      req.Email,
+		Subject: "Your signup",
+		Body:    body,
+	})
+	if err != nil {
+		log.Println("unable to send signup conflict:", err)
+	}
+}
+
+// startEmailVerification sends a new code verifying the current email of
+// the user, unless the user was sent too many codes lately, then it returns
+// ErrVerificationRateLimited.
+func (server *Server) startEmailVerification(ctx context.Context, user db.User) error {
+	sent, err := server.store.CountEmailVerificationsSince(ctx, db.CountEmailVerificationsSinceParams{
+		UserID: user.ID,
+		Since:  server.verificationLimits.since(),
+	})
+	if err != nil {
+		return err
+	}
+	if server.verificationLimits.wait(sent.Sent, sent.FirstSentAt, sent.LastSentAt) > 0 {
+		return ErrVerificationRateLimited
+	}
+
+	code, err := util.NewSecureToken(verificationCodeSize)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(server.tokenParams.EmailVerificationDuration)
+	_, err = server.store.CreateEmailVerification(ctx, db.CreateEmailVerificationParams{
+		UserID:    user.ID,
+		Email:     user.Email,
+		CodeHash:  util.HashToken(code),
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	return server.sendVerificationCode(ctx, user, code, expiresAt)
+}
+
+type resendEmailVerificationResponse struct {
+	Message string `json:"message"`
+}
+
+// resendEmailVerification sends a new verification code, e.g. after the
+// last one expired or got lost. Like forgotPassword, it answers the same
+// whether the user exists, is verified already or asks too often.
+func (server *Server) resendEmailVerification(ctx *gin.Context) {
+	var uri getUserUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	resp := resendEmailVerificationResponse{
+		Message: "if the email of the user is not verified, a new code has been sent",
+	}
+
+	user, err := server.store.GetUserByUsername(ctx, uri.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusAccepted, resp)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		ctx.JSON(http.StatusAccepted, resp)
+		return
+	}
+
+	err = server.startEmailVerification(ctx, user)
+	if err != nil && !errors.Is(err, ErrVerificationRateLimited) {
+		log.Println("unable to resend email verification:", err)
+	}
+	ctx.JSON(http.StatusAccepted, resp)
+}
+
+type verifyEmailRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// verifyEmail uses up a verification code and activates the user.
+// It needs no auth, since unverified users may be unable to log in.
+func (server *Server) verifyEmail(ctx *gin.Context) {
+	var uri getUserUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	var req verifyEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	// an unknown user gets the answer of a wrong code, so the route can not
+	// tell which usernames exist
+	user, err := server.store.GetUserByUsername(ctx, uri.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidVerificationCode))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	verification, err := server.store.GetEmailVerification(ctx, db.GetEmailVerificationParams{
+		UserID:   user.ID,
+		CodeHash: util.HashToken(req.Code),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidVerificationCode))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	result, err := server.store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{
+		EmailVerificationID: verification.ID,
+	})
+	if err != nil {
+		// the code could be used by a concurrent request or the email was changed
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidVerificationCode))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(result.User))
+}
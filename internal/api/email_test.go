@@ -0,0 +1,345 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestVerifyEmailAPI(t *testing.T) {
+	user := randomUser()
+	user.Status = userStatusPendingVerification
+
+	verified := user
+	verified.Status = userStatusActive
+	verified.EmailVerifiedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	code := util.RandomString(43)
+	verification := db.EmailVerification{
+		ID:       util.RandomInt(1, 1000),
+		UserID:   user.ID,
+		Email:    user.Email,
+		CodeHash: util.HashToken(code),
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetEmailVerification(gomock.Any(), gomock.Eq(db.GetEmailVerificationParams{
+						UserID:   user.ID,
+						CodeHash: verification.CodeHash,
+					})).
+					Times(1).
+					Return(verification, nil)
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Eq(db.VerifyEmailTxParams{
+						EmailVerificationID: verification.ID,
+					})).
+					Times(1).
+					Return(db.VerifyEmailTxResult{User: verified}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assertBodyMatchUser(t, recorder.Body, verified)
+			},
+		},
+		{
+			name: "InvalidCode",
+			body: gin.H{"code": "wrong"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetEmailVerification(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.EmailVerification{}, sql.ErrNoRows)
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), ErrInvalidVerificationCode.Error())
+			},
+		},
+		{
+			name: "EmailChanged",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetEmailVerification(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(verification, nil)
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.VerifyEmailTxResult{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "UserNotFound",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetEmailVerification(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				// same as InvalidCode
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), ErrInvalidVerificationCode.Error())
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetEmailVerification(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.EmailVerification{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "NoCode",
+			body: gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			url := fmt.Sprintf("/users/%s/verify_email", user.Username)
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestResendEmailVerificationAPI(t *testing.T) {
+	user := randomUser()
+	user.Status = userStatusPendingVerification
+
+	verified := user
+	verified.EmailVerifiedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountEmailVerificationsSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CountEmailVerificationsSinceParams) (db.CountEmailVerificationsSinceRow, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						assert.WithinDuration(t, time.Now().Add(-verificationLimitWindow), arg.Since.Time, time.Second)
+						return db.CountEmailVerificationsSinceRow{}, nil
+					})
+				store.EXPECT().
+					CreateEmailVerification(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateEmailVerificationParams) (db.EmailVerification, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						assert.Equal(t, user.Email, arg.Email)
+						return db.EmailVerification{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+				assert.Len(t, notifier.messages, 1)
+				assert.Equal(t, user.Email, notifier.messages[0].To)
+			},
+		},
+		{
+			name: "TooSoon",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountEmailVerificationsSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CountEmailVerificationsSinceRow{
+						Sent:        1,
+						FirstSentAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Second), Valid: true},
+						LastSentAt:  pgtype.Timestamptz{Time: time.Now().Add(-time.Second), Valid: true},
+					}, nil)
+				store.EXPECT().
+					CreateEmailVerification(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name: "DailyLimit",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountEmailVerificationsSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CountEmailVerificationsSinceRow{
+						Sent:        int64(DefaultVerificationLimits.DailyLimit),
+						FirstSentAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+						LastSentAt:  pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+					}, nil)
+				store.EXPECT().
+					CreateEmailVerification(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name: "AlreadyVerified",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(verified, nil)
+				store.EXPECT().
+					CountEmailVerificationsSince(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name: "UserNotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					CountEmailVerificationsSince(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			notifier := &testNotifier{}
+			server := newTestServer(t, store, WithNotifier(notifier))
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/users/%s/verify_email/resend", user.Username)
+			req, err := http.NewRequest(http.MethodPost, url, nil)
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder, notifier)
+		})
+	}
+}
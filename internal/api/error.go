@@ -14,6 +14,8 @@ var (
 	ErrMissingAuthPayload = errors.New("missing auth payload")
 	ErrNotFound           = errors.New("not found")
 	ErrPermissionDenied   = errors.New("permission denied")
+	ErrInsufficientScope  = errors.New("token does not have the required scope")
+	ErrBearerRequired     = errors.New("this route needs a bearer token")
 )
 
 func errorResponse(err error) gin.H {
@@ -23,3 +25,8 @@ func errorResponse(err error) gin.H {
 
 	return gin.H{"error": err.Error()}
 }
+
+// fieldErrorResponse tells which request fields are wrong and why.
+func fieldErrorResponse(err error, fields map[string][]string) gin.H {
+	return gin.H{"error": err.Error(), "fields": fields}
+}
@@ -1,6 +1,9 @@
 package api
 
 import (
+	"regexp"
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 	"github.com/mauzec/user-api/internal/util"
 )
@@ -17,3 +20,22 @@ func validGender(fl validator.FieldLevel) bool {
 	}
 	return false
 }
+
+var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+// validIdentifier accepts what identifies a user at login: a username,
+// an email or an E.164 phone number.
+func validIdentifier(fl validator.FieldLevel) bool {
+	identifier, ok := fl.Field().Interface().(string)
+	if !ok {
+		return false
+	}
+	switch identifierKind(identifier) {
+	case identifierEmail:
+		local, domain, _ := strings.Cut(identifier, "@")
+		return local != "" && domain != ""
+	case identifierPhone:
+		return util.IsValidPhoneNumber(identifier)
+	}
+	return usernameRegex.MatchString(identifier)
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/notify"
+)
+
+var ErrTooManyLoginAttempts = errors.New("too many failed login attempts, try again later")
+
+// LoginThrottleParams sets how failed logins are slowed down. Failures are
+// counted per username and per client ip. Once a counter reaches its backoff
+// threshold, each further attempt has to wait twice as long as the previous
+// one, up to BackoffMax. A zero threshold turns that protection off.
+type LoginThrottleParams struct {
+	// failures older than this are forgotten
+	FailureWindow time.Duration
+
+	BackoffThreshold   int
+	IPBackoffThreshold int
+	BackoffBase        time.Duration
+	BackoffMax         time.Duration
+
+	// failures of a username after which logging in as it is refused for
+	// LockoutDuration; the user keeps their status and sessions
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+}
+
+// DefaultLoginThrottleParams are used unless set by WithLoginThrottle.
+var DefaultLoginThrottleParams = LoginThrottleParams{
+	FailureWindow:      15 * time.Minute,
+	BackoffThreshold:   3,
+	IPBackoffThreshold: 20,
+	BackoffBase:        time.Second,
+	BackoffMax:         time.Minute,
+	LockoutThreshold:   10,
+	LockoutDuration:    15 * time.Minute,
+}
+
+// loginThrottle keeps the failed login counters in the database, so every
+// instance sees the same counts.
+type loginThrottle struct {
+	store  db.Store
+	params LoginThrottleParams
+}
+
+func newLoginThrottle(store db.Store, params LoginThrottleParams) *loginThrottle {
+	return &loginThrottle{store: store, params: params}
+}
+
+func usernameFailureKey(username string) string {
+	return "username:" + username
+}
+
+func ipFailureKey(ip string) string {
+	return "ip:" + ip
+}
+
+// backoff returns how long to wait after the last of the failures.
+func (t *loginThrottle) backoff(failures int32, threshold int) time.Duration {
+	if threshold <= 0 || int(failures) < threshold {
+		return 0
+	}
+	exp := float64(int(failures) - threshold)
+	delay := float64(t.params.BackoffBase) * math.Pow(2, exp)
+	if t.params.BackoffMax > 0 && delay > float64(t.params.BackoffMax) {
+		return t.params.BackoffMax
+	}
+	return time.Duration(delay)
+}
+
+// wait returns how long the client has to wait before trying to log in as
+// the username again, or zero if it may try now. A locked out username has
+// to wait until the lockout is over.
+func (t *loginThrottle) wait(ctx context.Context, username, ip string) (time.Duration, error) {
+	usernameKey := usernameFailureKey(username)
+
+	var longest time.Duration
+	for key, threshold := range map[string]int{
+		usernameKey:      t.params.BackoffThreshold,
+		ipFailureKey(ip): t.params.IPBackoffThreshold,
+	} {
+		// an ip can be shared by many users, so only usernames are locked out
+		lockable := key == usernameKey && t.params.LockoutThreshold > 0
+		if threshold <= 0 && !lockable {
+			continue
+		}
+		failure, err := t.store.GetLoginFailure(ctx, key)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return 0, err
+		}
+
+		delay := t.backoff(failure.Failures, threshold)
+		if lockable && t.shouldLock(failure.Failures) {
+			delay = max(delay, t.params.LockoutDuration)
+		} else if time.Since(failure.LastFailedAt.Time) >= t.params.FailureWindow {
+			continue
+		}
+		longest = max(longest, time.Until(failure.LastFailedAt.Time.Add(delay)))
+	}
+	return longest, nil
+}
+
+// fail counts a failed login for the username and the ip and returns how
+// many times in a row logging in as the username has failed.
+func (t *loginThrottle) fail(ctx context.Context, username, ip string) (int32, error) {
+	windowStart := pgtype.Timestamptz{Time: time.Now().Add(-t.params.FailureWindow), Valid: true}
+
+	if _, err := t.store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Key:         ipFailureKey(ip),
+		WindowStart: windowStart,
+	}); err != nil {
+		return 0, err
+	}
+	failure, err := t.store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Key:         usernameFailureKey(username),
+		WindowStart: windowStart,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return failure.Failures, nil
+}
+
+// shouldLock reports whether a username has failed to log in often enough to be locked out.
+func (t *loginThrottle) shouldLock(failures int32) bool {
+	return t.params.LockoutThreshold > 0 && int(failures) >= t.params.LockoutThreshold
+}
+
+// reset forgets the failures counted under the keys.
+func (t *loginThrottle) reset(ctx context.Context, keys ...string) {
+	err := t.store.ResetLoginFailures(ctx, keys)
+	if err != nil {
+		log.Println("unable to reset login failures:", err)
+	}
+}
+
+// failLogin counts the failed login and responds with 401 and the failure,
+// the same as for unknown users. If the user has now failed too many times,
+// wait refuses to log them in for a while, and they are told so by email.
+// The lockout is kept with the failures only: the status of the user is not
+// changed, so their sessions and API keys keep working.
+func (server *Server) failLogin(ctx *gin.Context, user db.User, failure error) {
+	failures, err := server.loginThrottle.fail(ctx, user.Username, ctx.ClientIP())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	if server.loginThrottle.shouldLock(failures) {
+		server.sendLockNotice(ctx, user, time.Now().Add(server.loginThrottle.params.LockoutDuration))
+	}
+	ctx.JSON(http.StatusUnauthorized, errorResponse(failure))
+}
+
+// sendLockNotice tells the user that failed logins locked them out. The
+// login answers the same either way, so a failure to send is only logged.
+func (server *Server) sendLockNotice(ctx context.Context, user db.User, expiresAt time.Time) {
+	err := server.notifier.Notify(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Too many failed logins",
+		Body: fmt.Sprintf(
+			"Logging in as %s failed too many times, so logging in is blocked until %s.\n"+
+				"If it was not you, reset your password once the lock lifts.",
+			user.Username, expiresAt.Format(time.RFC1123),
+		),
+	})
+	if err != nil {
+		log.Println("unable to send lock notice:", err)
+	}
+}
+
+// setRetryAfter tells the client how many seconds to wait before trying again.
+func setRetryAfter(ctx *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	ctx.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+}
+
+// DeleteStaleLoginFailures forgets failed logins older than the failure
+// window, or the lockout if it is longer. It is meant to be run periodically.
+func (server *Server) DeleteStaleLoginFailures(ctx context.Context) error {
+	params := server.loginThrottle.params
+	return server.store.DeleteStaleLoginFailures(ctx, pgtype.Timestamptz{
+		Time:  time.Now().Add(-max(params.FailureWindow, params.LockoutDuration)),
+		Valid: true,
+	})
+}
@@ -0,0 +1,366 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	throttle := newLoginThrottle(nil, LoginThrottleParams{
+		BackoffBase: time.Second,
+		BackoffMax:  time.Minute,
+	})
+
+	assert.Zero(t, throttle.backoff(2, 3))
+	assert.Equal(t, time.Second, throttle.backoff(3, 3))
+	assert.Equal(t, 2*time.Second, throttle.backoff(4, 3))
+	assert.Equal(t, 16*time.Second, throttle.backoff(7, 3))
+	assert.Equal(t, time.Minute, throttle.backoff(100, 3))
+	assert.Zero(t, throttle.backoff(100, 0))
+}
+
+func TestLoginThrottleAPI(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+	clientIP := "203.0.113.7"
+	userKey := usernameFailureKey(user.Username)
+	ipKey := ipFailureKey(clientIP)
+
+	params := LoginThrottleParams{
+		FailureWindow:      15 * time.Minute,
+		BackoffThreshold:   3,
+		IPBackoffThreshold: 20,
+		BackoffBase:        time.Second,
+		BackoffMax:         time.Minute,
+		LockoutThreshold:   5,
+		LockoutDuration:    10 * time.Minute,
+	}
+
+	noFailures := func(store *mockdb.MockStore) {
+		store.EXPECT().
+			GetLoginFailure(gomock.Any(), gomock.Any()).
+			Times(2).
+			Return(db.LoginFailure{}, sql.ErrNoRows)
+	}
+	failures := func(key string, count int32, at time.Time) func(store *mockdb.MockStore) {
+		return func(store *mockdb.MockStore) {
+			store.EXPECT().
+				GetLoginFailure(gomock.Any(), gomock.Eq(key)).
+				AnyTimes().
+				Return(db.LoginFailure{
+					Key:          key,
+					Failures:     count,
+					LastFailedAt: pgtype.Timestamptz{Time: at, Valid: true},
+				}, nil)
+			store.EXPECT().
+				GetLoginFailure(gomock.Any(), gomock.Any()).
+				AnyTimes().
+				Return(db.LoginFailure{}, sql.ErrNoRows)
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		password      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier)
+	}{
+		{
+			name:     "UsernameBackoff",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				failures(userKey, 4, time.Now())(store)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				assert.Equal(t, "2", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name:     "IPBackoff",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				failures(ipKey, 20, time.Now())(store)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name:     "BackoffOver",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				failures(userKey, 4, time.Now().Add(-5*time.Second))(store)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ResetLoginFailures(gomock.Any(), gomock.Eq([]string{userKey, ipKey})).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "MfaPending",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				noFailures(store)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{
+						UserID:      user.ID,
+						ConfirmedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+					}, nil)
+				store.EXPECT().
+					CreateMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.MfaChallenge{}, nil)
+				// wrong codes add up until the code is right
+				store.EXPECT().
+					ResetLoginFailures(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "WrongPassword",
+			password: "wrong_password",
+			buildStubs: func(store *mockdb.MockStore) {
+				noFailures(store)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(_ context.Context, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+						assert.WithinDuration(t, time.Now().Add(-params.FailureWindow), arg.WindowStart.Time, time.Second)
+						return db.LoginFailure{Key: arg.Key, Failures: 4}, nil
+					})
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "Lockout",
+			password: "wrong_password",
+			buildStubs: func(store *mockdb.MockStore) {
+				noFailures(store)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(_ context.Context, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+						return db.LoginFailure{Key: arg.Key, Failures: 5}, nil
+					})
+				// the lockout is kept with the failures, the sessions and API
+				// keys of the user keep working
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					ResetLoginFailures(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				// the same answer as for unknown users, the lock is told by email
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Empty(t, recorder.Header().Get("Retry-After"))
+				assert.Len(t, notifier.messages, 1)
+				assert.Equal(t, user.Email, notifier.messages[0].To)
+			},
+		},
+		{
+			name:     "LockedOut",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				failures(userKey, 5, time.Now().Add(-5*time.Minute))(store)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				// the rest of the lockout, not of the backoff
+				assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				assert.Equal(t, "300", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name:     "LockoutOver",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				failures(userKey, 5, time.Now().Add(-11*time.Minute))(store)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ResetLoginFailures(gomock.Any(), gomock.Eq([]string{userKey, ipKey})).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "Locked",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				locked := user
+				locked.Status = userStatusLocked
+				locked.StatusExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(90 * time.Second), Valid: true}
+				noFailures(store)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(locked, nil)
+				// counted like the failures of unknown users
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(db.LoginFailure{Failures: 1}, nil)
+				store.EXPECT().
+					ResetLoginFailures(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Empty(t, recorder.Header().Get("Retry-After"))
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name:     "UnknownUser",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				noFailures(store)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(db.LoginFailure{Failures: 1}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "GetLoginFailureError",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.LoginFailure{}, sql.ErrConnDone)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			notifier := &testNotifier{}
+			server := newTestServer(t, store, WithLoginThrottle(params), WithNotifier(notifier))
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{"identifier": user.Username, "password": tc.password})
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
+			assert.NoError(t, err)
+			req.RemoteAddr = clientIP + ":54321"
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder, notifier)
+		})
+	}
+}
@@ -1,14 +1,17 @@
 package api
 
 import (
+	"database/sql"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	mockdb "github.com/mauzec/user-api/db/mock"
 	db "github.com/mauzec/user-api/db/sqlc"
 	"github.com/mauzec/user-api/internal/token"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
 )
 
 func TestMain(m *testing.M) {
@@ -16,13 +19,48 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
-func newTestServer(t *testing.T, store db.Store) *Server {
+func newTestServer(t *testing.T, store db.Store, opts ...Option) *Server {
 	tokenMaker, err := token.NewPasetoSMaker("12345678901234567890123456789012")
 	assert.NoError(t, err)
 
-	tokenParams := TokenParams{time.Minute * 15}
-	server, err := NewServer(store, tokenMaker, tokenParams)
+	tokenParams := TokenParams{
+		AccessTokenDuration:        time.Minute * 15,
+		RefreshTokenDuration:       time.Hour * 24,
+		PasswordResetTokenDuration: time.Minute * 30,
+		EmailVerificationDuration:  time.Hour * 24,
+		PhoneVerificationDuration:  time.Minute * 10,
+		MfaTokenDuration:           time.Minute * 5,
+	}
+	server, err := NewServer(store, tokenMaker, tokenParams, opts...)
 	assert.NoError(t, err)
 
 	return server
 }
+
+// stubAuthChecks lets the checks of authMiddleware pass for any token.
+func stubAuthChecks(store *mockdb.MockStore) {
+	store.EXPECT().
+		ListRevokedTokensSince(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return([]db.RevokedToken{}, nil)
+	store.EXPECT().
+		GetUserAuthState(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(db.GetUserAuthStateRow{Status: userStatusActive}, nil)
+}
+
+// stubLoginThrottle lets logins pass the failed login throttle as if no login had failed before.
+func stubLoginThrottle(store *mockdb.MockStore) {
+	store.EXPECT().
+		GetLoginFailure(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(db.LoginFailure{}, sql.ErrNoRows)
+	store.EXPECT().
+		RecordLoginFailure(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(db.LoginFailure{Failures: 1}, nil)
+	store.EXPECT().
+		ResetLoginFailures(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(nil)
+}
@@ -1,9 +1,11 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -13,11 +15,24 @@ import (
 const (
 	authHeaderKey  = "authorization"
 	authPayloadKey = "auth_payload"
+	// the auth type of the request, see getAuthType
+	authTypeKey = "auth_type"
 
 	authTypeBearer = "bearer"
+	authTypeAPIKey = "apikey"
 )
 
-func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+// payloadCheck is an additional check run on a verified token payload,
+// e.g. against the revoked tokens denylist.
+type payloadCheck func(ctx context.Context, payload *token.Payload) error
+
+// apiKeyResolver finds the owner of an API key and describes the key
+// as a token payload, so handlers treat both auth types the same way.
+type apiKeyResolver func(ctx context.Context, key string) (*token.Payload, error)
+
+// authMiddleware accepts bearer tokens and, if apiKeys is not nil, API keys.
+// The checks are run only for bearer tokens.
+func authMiddleware(tokenMaker token.Maker, apiKeys apiKeyResolver, checks ...payloadCheck) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authHeader := ctx.GetHeader(authHeaderKey)
 		if len(authHeader) == 0 {
@@ -42,7 +57,17 @@ func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 		givenToken := fields[1]
 		switch authType {
 		case authTypeBearer:
-			handleBearer(ctx, tokenMaker, givenToken)
+			handleBearer(ctx, tokenMaker, givenToken, checks)
+		case authTypeAPIKey:
+			if apiKeys == nil {
+				err := fmt.Errorf("unsupported auth type %s", authType)
+				ctx.AbortWithStatusJSON(
+					http.StatusUnauthorized,
+					errorResponse(err),
+				)
+				return
+			}
+			handleAPIKey(ctx, apiKeys, givenToken)
 
 		default:
 			err := fmt.Errorf("unsupported auth type %s", authType)
@@ -57,8 +82,23 @@ func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 	}
 }
 
-func handleBearer(ctx *gin.Context, tokenMaker token.Maker, token string) {
-	p, err := tokenMaker.VerifyToken(token)
+// verifyToken verifies the token, checks that it is of the type and
+// normalizes its username, tokens issued before usernames were stored
+// lowercase may carry them in any casing.
+func verifyToken(tokenMaker token.Maker, givenToken string, tokenType token.Type) (*token.Payload, error) {
+	p, err := tokenMaker.VerifyToken(givenToken)
+	if p != nil {
+		if p.Type != tokenType {
+			return nil, token.ErrWrongTokenType
+		}
+		p.Username = normalizeUsername(p.Username)
+	}
+	return p, err
+}
+
+func handleBearer(ctx *gin.Context, tokenMaker token.Maker, givenToken string, checks []payloadCheck) {
+	// refresh tokens live much longer, they must not authorize requests
+	p, err := verifyToken(tokenMaker, givenToken, token.TypeAccess)
 	if err != nil {
 		ctx.AbortWithStatusJSON(
 			http.StatusUnauthorized,
@@ -66,5 +106,100 @@ func handleBearer(ctx *gin.Context, tokenMaker token.Maker, token string) {
 		)
 		return
 	}
+
+	for _, check := range checks {
+		if err := check(ctx, p); err != nil {
+			ctx.AbortWithStatusJSON(authErrorCode(err), errorResponse(err))
+			return
+		}
+	}
+	ctx.Set(authPayloadKey, p)
+	ctx.Set(authTypeKey, authTypeBearer)
+}
+
+func handleAPIKey(ctx *gin.Context, apiKeys apiKeyResolver, key string) {
+	p, err := apiKeys(ctx, key)
+	if err != nil {
+		ctx.AbortWithStatusJSON(authErrorCode(err), errorResponse(err))
+		return
+	}
 	ctx.Set(authPayloadKey, p)
+	ctx.Set(authTypeKey, authTypeAPIKey)
+}
+
+// authErrorCode returns the http status code for an error of a payloadCheck
+// or an apiKeyResolver. Users kept out by their status get the code of the
+// status, any other failure is unauthorized.
+func authErrorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrInternalServerError):
+		return http.StatusInternalServerError
+	case errors.Is(err, ErrUserSuspended), errors.Is(err, ErrUserBanned), errors.Is(err, ErrUserLocked):
+		return statusErrorCode(err)
+	}
+	return http.StatusUnauthorized
+}
+
+// normalizeUsernameParam lowercases the :username path parameter, so routes
+// find users whatever casing the client used.
+func normalizeUsernameParam() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		for i, param := range ctx.Params {
+			if param.Key == "username" {
+				ctx.Params[i].Value = normalizeUsername(param.Value)
+			}
+		}
+		ctx.Next()
+	}
+}
+
+// getAuthType returns how the request was authenticated, authTypeBearer or authTypeAPIKey.
+func getAuthType(ctx *gin.Context) string {
+	return ctx.GetString(authTypeKey)
+}
+
+// requireBearer rejects requests authenticated with an API key, for routes
+// that work with sessions or sensitive settings. It must run after authMiddleware.
+func requireBearer() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if getAuthType(ctx) != authTypeBearer {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(ErrBearerRequired))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// requireScope lets through only requests whose auth token was granted the scope.
+// It must run after authMiddleware.
+func requireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, err := getAuthPayload(ctx)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		if !payload.HasScope(scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(ErrInsufficientScope))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// requireRole lets through only requests whose auth token carries one of the roles.
+// It must run after authMiddleware.
+func requireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, err := getAuthPayload(ctx)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		if !slices.Contains(roles, payload.Role) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(ErrPermissionDenied))
+			return
+		}
+		ctx.Next()
+	}
 }
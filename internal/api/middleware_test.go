@@ -1,6 +1,7 @@
 package api
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -8,15 +9,35 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
 	"github.com/mauzec/user-api/internal/token"
+	"github.com/mauzec/user-api/internal/util"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
 )
 
 func addAuthHeader(
 	t *testing.T, req *http.Request, tokenMaker token.Maker,
 	authType string, username string, duration time.Duration,
 ) {
-	token, err := tokenMaker.CreateToken(username, duration)
+	addRoleAuthHeader(t, req, tokenMaker, authType, username, util.RoleUser, duration)
+}
+
+// addRoleAuthHeader adds a token with all scopes of the role.
+func addRoleAuthHeader(
+	t *testing.T, req *http.Request, tokenMaker token.Maker,
+	authType string, username string, role string, duration time.Duration,
+) {
+	addScopedAuthHeader(t, req, tokenMaker, authType, username, role, util.RoleScopes(role), duration)
+}
+
+func addScopedAuthHeader(
+	t *testing.T, req *http.Request, tokenMaker token.Maker,
+	authType string, username string, role string, scopes []string, duration time.Duration,
+) {
+	token, _, err := tokenMaker.CreateToken(token.TypeAccess, username, role, scopes, duration)
 	assert.NoError(t, err)
 
 	authHeader := fmt.Sprintf("%s %s", authType, token)
@@ -65,7 +86,7 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			"BigCountFieldsInAuthHeader",
 			func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
-				token, err := tokenMaker.CreateToken("user", time.Minute)
+				token, _, err := tokenMaker.CreateToken(token.TypeAccess, "user", util.RoleUser, nil, time.Minute)
 				assert.NoError(t, err)
 				req.Header.Set(authHeaderKey,
 					fmt.Sprintf("%s %s 123 hello", authTypeBearer, token))
@@ -77,7 +98,7 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			"WrongAuthHeaderPattern",
 			func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
-				token, err := tokenMaker.CreateToken("user", time.Minute)
+				token, _, err := tokenMaker.CreateToken(token.TypeAccess, "user", util.RoleUser, nil, time.Minute)
 				assert.NoError(t, err)
 				req.Header.Set(authHeaderKey,
 					fmt.Sprintf("- %s %s", authTypeBearer, token))
@@ -87,6 +108,18 @@ func TestAuthMiddleware(t *testing.T) {
 			},
 		},
 
+		{
+			"RefreshToken",
+			func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				refreshToken, _, err := tokenMaker.CreateToken(token.TypeRefresh, "user", util.RoleUser, nil, time.Hour)
+				assert.NoError(t, err)
+				req.Header.Set(authHeaderKey, fmt.Sprintf("%s %s", authTypeBearer, refreshToken))
+			},
+			func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), token.ErrWrongTokenType.Error())
+			},
+		},
 		{
 			"ExpiredToken",
 			func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
@@ -108,7 +141,7 @@ func TestAuthMiddleware(t *testing.T) {
 
 			authPath := "/auth"
 			server.router.GET(authPath,
-				authMiddleware(server.tokenMaker),
+				authMiddleware(server.tokenMaker, nil),
 				func(ctx *gin.Context) {
 					ctx.JSON(http.StatusOK, gin.H{})
 				},
@@ -124,3 +157,304 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthMiddlewareRevokedToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	server := newTestServer(t, store)
+	revokedToken, revokedPayload, err := server.tokenMaker.CreateToken(token.TypeAccess, "user", util.RoleUser, nil, time.Minute)
+	assert.NoError(t, err)
+	validToken, _, err := server.tokenMaker.CreateToken(token.TypeAccess, "user", util.RoleUser, nil, time.Minute)
+	assert.NoError(t, err)
+
+	// revoked by another instance, so it comes only from the database
+	store.EXPECT().
+		ListRevokedTokensSince(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]db.RevokedToken{{
+			ID:        revokedPayload.ID,
+			Username:  revokedPayload.Username,
+			ExpiresAt: pgtype.Timestamptz{Time: revokedPayload.ExpiredAt, Valid: true},
+			RevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		}}, nil)
+
+	authPath := "/auth"
+	server.router.GET(authPath,
+		authMiddleware(server.tokenMaker, nil, server.denylist.check),
+		func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{})
+		},
+	)
+
+	for _, tc := range []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"RevokedToken", revokedToken, http.StatusUnauthorized},
+		{"ValidToken", validToken, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, authPath, nil)
+			assert.NoError(t, err)
+			req.Header.Set(authHeaderKey, fmt.Sprintf("%s %s", authTypeBearer, tc.token))
+
+			server.router.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.status, recorder.Code)
+		})
+	}
+}
+
+func TestAuthMiddlewareDenylistError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ListRevokedTokensSince(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil, sql.ErrConnDone)
+
+	server := newTestServer(t, store)
+
+	authPath := "/auth"
+	server.router.GET(authPath,
+		authMiddleware(server.tokenMaker, nil, server.denylist.check),
+		func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{})
+		},
+	)
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, authPath, nil)
+	assert.NoError(t, err)
+	addAuthHeader(t, req, server.tokenMaker, authTypeBearer, "user", time.Minute)
+
+	server.router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
+
+func TestAuthMiddlewareUserState(t *testing.T) {
+	testCases := []struct {
+		name       string
+		buildStubs func(store *mockdb.MockStore)
+		status     int
+		// repeated requests must be served from the cache
+		requests int
+	}{
+		{
+			name: "NeverChanged",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserAuthState(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthStateRow{Status: userStatusActive}, nil)
+			},
+			status:   http.StatusOK,
+			requests: 2,
+		},
+		{
+			name: "ChangedBeforeIssue",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserAuthState(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthStateRow{
+						PasswordChangedAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+						Status:            userStatusActive,
+					}, nil)
+			},
+			status:   http.StatusOK,
+			requests: 2,
+		},
+		{
+			name: "ChangedAfterIssue",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserAuthState(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthStateRow{
+						PasswordChangedAt: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+						Status:            userStatusActive,
+					}, nil)
+			},
+			status:   http.StatusUnauthorized,
+			requests: 2,
+		},
+		{
+			name: "RevokedAfterIssue",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserAuthState(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthStateRow{
+						TokensValidAfter: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+						Status:           userStatusActive,
+					}, nil)
+			},
+			status:   http.StatusUnauthorized,
+			requests: 2,
+		},
+		{
+			name: "UserNotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserAuthState(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthStateRow{}, sql.ErrNoRows)
+			},
+			status:   http.StatusUnauthorized,
+			requests: 1,
+		},
+		{
+			name: "Suspended",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserAuthState(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthStateRow{Status: userStatusSuspended}, nil)
+			},
+			status:   http.StatusForbidden,
+			requests: 2,
+		},
+		{
+			name: "Locked",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserAuthState(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthStateRow{
+						Status:          userStatusLocked,
+						StatusExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+					}, nil)
+			},
+			status:   http.StatusLocked,
+			requests: 1,
+		},
+		{
+			name: "BanExpired",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserAuthState(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthStateRow{
+						Status:          userStatusBanned,
+						StatusExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true},
+						EmailVerifiedAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+					}, nil)
+			},
+			status:   http.StatusOK,
+			requests: 1,
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserAuthState(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthStateRow{}, sql.ErrConnDone)
+			},
+			status:   http.StatusInternalServerError,
+			requests: 1,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+
+			authPath := "/auth"
+			server.router.GET(authPath,
+				authMiddleware(server.tokenMaker, nil, server.userStates.check),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, gin.H{})
+				},
+			)
+
+			for range tc.requests {
+				recorder := httptest.NewRecorder()
+				req, err := http.NewRequest(http.MethodGet, authPath, nil)
+				assert.NoError(t, err)
+				addAuthHeader(t, req, server.tokenMaker, authTypeBearer, "user", time.Minute)
+
+				server.router.ServeHTTP(recorder, req)
+				assert.Equal(t, tc.status, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestRequireRoleMiddleware(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	rolePath := "/role"
+	server.router.GET(rolePath,
+		authMiddleware(server.tokenMaker, nil),
+		requireRole(util.RoleModerator, util.RoleAdmin),
+		func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{})
+		},
+	)
+
+	for _, tc := range []struct {
+		role   string
+		status int
+	}{
+		{util.RoleUser, http.StatusForbidden},
+		{util.RoleModerator, http.StatusOK},
+		{util.RoleAdmin, http.StatusOK},
+		{"", http.StatusForbidden},
+	} {
+		t.Run(tc.role, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, rolePath, nil)
+			assert.NoError(t, err)
+			addRoleAuthHeader(t, req, server.tokenMaker, authTypeBearer, "user", tc.role, time.Minute)
+
+			server.router.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.status, recorder.Code)
+		})
+	}
+}
+
+func TestRequireScopeMiddleware(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	scopePath := "/scope"
+	server.router.GET(scopePath,
+		authMiddleware(server.tokenMaker, nil),
+		requireScope(util.ScopeUsersWrite),
+		func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{})
+		},
+	)
+
+	for _, tc := range []struct {
+		name   string
+		scopes []string
+		status int
+	}{
+		{"Granted", []string{util.ScopeUsersRead, util.ScopeUsersWrite}, http.StatusOK},
+		{"ReadOnly", []string{util.ScopeUsersRead}, http.StatusForbidden},
+		{"NoScopes", nil, http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, scopePath, nil)
+			assert.NoError(t, err)
+			addScopedAuthHeader(t, req, server.tokenMaker, authTypeBearer, "user", util.RoleUser, tc.scopes, time.Minute)
+
+			server.router.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.status, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/notify"
+	"github.com/mauzec/user-api/internal/util"
+)
+
+var ErrInvalidCurrentPassword = errors.New("invalid current password")
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// changePassword sets a new password. Every token issued before
+// the change, including the one of this request, stops working.
+func (server *Server) changePassword(ctx *gin.Context) {
+	var uri getUserUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	var req changePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	payload, err := getAuthPayload(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	if payload.Username != uri.Username {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(ErrPermissionDenied))
+		return
+	}
+
+	user, err := server.store.GetUserByUsername(ctx, uri.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ErrNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	if err = server.passwordHasher.Check(user.HashedPassword, req.CurrentPassword); err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(ErrInvalidCurrentPassword))
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("new password must differ from the current one")))
+		return
+	}
+	if !server.checkNewPassword(ctx, "new_password", req.NewPassword, passwordOwner(user)) {
+		return
+	}
+
+	hashedPassword, err := server.passwordHasher.Hash(req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	changedAt := time.Now()
+	updated, err := server.store.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
+		ID:                user.ID,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: pgtype.Timestamptz{Time: changedAt, Valid: true},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	server.userStates.set(updated)
+
+	ctx.JSON(http.StatusOK, newUserResponse(updated))
+}
+
+// resetTokenSize is the number of random bytes in a password reset token
+const resetTokenSize = 32
+
+type forgotPasswordRequest struct {
+	Username string `json:"username" binding:"required,alphanum"`
+}
+
+type forgotPasswordResponse struct {
+	Message string `json:"message"`
+}
+
+// forgotPassword sends a single-use reset token to the user. Tokens are
+// sent as often as verification codes, see VerificationLimits. The response
+// is the same whether the user exists, is sent a token or asks too often.
+func (server *Server) forgotPassword(ctx *gin.Context) {
+	var req forgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	resp := forgotPasswordResponse{
+		Message: "if the user exists, a password reset token has been sent",
+	}
+
+	user, err := server.store.GetUserByUsername(ctx, normalizeUsername(req.Username))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusAccepted, resp)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	sent, err := server.store.CountPasswordResetTokensSince(ctx, db.CountPasswordResetTokensSinceParams{
+		UserID: user.ID,
+		Since:  server.verificationLimits.since(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	if server.verificationLimits.wait(sent.Sent, sent.FirstSentAt, sent.LastSentAt) > 0 {
+		ctx.JSON(http.StatusAccepted, resp)
+		return
+	}
+
+	resetToken, err := util.NewSecureToken(resetTokenSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	expiresAt := time.Now().Add(server.tokenParams.PasswordResetTokenDuration)
+	_, err = server.store.CreatePasswordResetToken(ctx, db.CreatePasswordResetTokenParams{
+		UserID:    user.ID,
+		TokenHash: util.HashToken(resetToken),
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	err = server.notifier.Notify(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf(
+			"Use this token to reset the password of %s: %s\nIt expires at %s.",
+			user.Username, resetToken, expiresAt.Format(time.RFC1123),
+		),
+	})
+	if err != nil {
+		// answering with an error would tell that the user exists
+		log.Println("unable to send password reset token:", err)
+	}
+
+	ctx.JSON(http.StatusAccepted, resp)
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+var ErrInvalidResetToken = errors.New("reset token is invalid or expired")
+
+// resetPassword uses up a reset token and sets the new password.
+func (server *Server) resetPassword(ctx *gin.Context) {
+	var req resetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	resetToken, err := server.store.GetPasswordResetToken(ctx, util.HashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidResetToken))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	user, err := server.store.GetUserByID(ctx, resetToken.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidResetToken))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	if !server.checkNewPassword(ctx, "new_password", req.NewPassword, passwordOwner(user)) {
+		return
+	}
+
+	hashedPassword, err := server.passwordHasher.Hash(req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	changedAt := time.Now()
+	result, err := server.store.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+		ResetTokenID:      resetToken.ID,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: pgtype.Timestamptz{Time: changedAt, Valid: true},
+	})
+	if err != nil {
+		// the token could be used by a concurrent request
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidResetToken))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	server.userStates.set(result.User)
+
+	ctx.JSON(http.StatusOK, newUserResponse(result.User))
+}
+
+// checkNewPassword checks a new password against the password policy. If
+// it breaks the policy, it responds with 400 and the reasons under the
+// request field the password came in.
+func (server *Server) checkNewPassword(ctx *gin.Context, field, password string, owner util.PasswordOwner) bool {
+	err := server.passwordPolicy.Check(password, owner)
+	if err == nil {
+		return true
+	}
+
+	var policyErr *util.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return false
+	}
+	ctx.JSON(http.StatusBadRequest, fieldErrorResponse(util.ErrPasswordPolicy, map[string][]string{
+		field: policyErr.Messages(),
+	}))
+	return false
+}
+
+func passwordOwner(user db.User) util.PasswordOwner {
+	return util.PasswordOwner{Username: user.Username, Email: user.Email}
+}
+
+// checkDummyPassword checks the password against a hash made by the hasher
+// of the server, so answering for an unknown user takes as long as
+// checking the password of a real one.
+func (server *Server) checkDummyPassword(password string) {
+	server.dummyHashOnce.Do(func() {
+		hashedPassword, err := server.passwordHasher.Hash(util.RandomString(16))
+		if err != nil {
+			log.Println("unable to create dummy password hash:", err)
+			return
+		}
+		server.dummyHash = hashedPassword
+	})
+	_ = server.passwordHasher.Check(server.dummyHash, password)
+}
+
+// rehashTimeout bounds a background rehash, it must not pile up under load
+const rehashTimeout = 10 * time.Second
+
+// rehashPassword replaces a hash made with another algorithm or older
+// params, once the user has logged in with the right password. It runs
+// in the background, so the login does not wait for the new hash. The
+// update is skipped if the password has been changed meanwhile.
+func (server *Server) rehashPassword(user db.User, password string) {
+	if !server.passwordHasher.NeedsRehash(user.HashedPassword) {
+		return
+	}
+
+	go func() {
+		hashedPassword, err := server.passwordHasher.Hash(password)
+		if err != nil {
+			log.Println("unable to rehash password:", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), rehashTimeout)
+		defer cancel()
+		err = server.store.RehashUserPassword(ctx, db.RehashUserPasswordParams{
+			ID:                user.ID,
+			NewHashedPassword: hashedPassword,
+			OldHashedPassword: user.HashedPassword,
+		})
+		if err != nil {
+			log.Println("unable to store rehashed password:", err)
+		}
+	}()
+}
@@ -0,0 +1,668 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/notify"
+	"github.com/mauzec/user-api/internal/token"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type eqUpdateUserPasswordMatcher struct {
+	id       int64
+	password string
+}
+
+func (e eqUpdateUserPasswordMatcher) Matches(x any) bool {
+	arg, ok := x.(db.UpdateUserPasswordParams)
+	if !ok {
+		return false
+	}
+	if arg.ID != e.id || !arg.PasswordChangedAt.Valid {
+		return false
+	}
+	return util.CheckPassword(arg.HashedPassword, e.password) == nil
+}
+
+func (e eqUpdateUserPasswordMatcher) String() string {
+	return fmt.Sprintf("matches user id %v and password %v", e.id, e.password)
+}
+
+// assertPasswordFieldErrors checks that the response lists the password
+// policy violations under the field.
+func assertPasswordFieldErrors(t *testing.T, recorder *httptest.ResponseRecorder, field string, violations ...error) {
+	var resp struct {
+		Error  string              `json:"error"`
+		Fields map[string][]string `json:"fields"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, util.ErrPasswordPolicy.Error(), resp.Error)
+	assert.Len(t, resp.Fields[field], len(violations))
+	for i, violation := range violations {
+		assert.Contains(t, resp.Fields[field][i], violation.Error())
+	}
+}
+
+func TestChangePasswordAPI(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+	newPassword := util.RandomString(12)
+
+	testCases := []struct {
+		name          string
+		username      string
+		body          gin.H
+		setupAuth     func(t *testing.T, req *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			body:     gin.H{"current_password": password, "new_password": newPassword},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), eqUpdateUserPasswordMatcher{user.ID, newPassword}).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assertBodyMatchUser(t, recorder.Body, user)
+			},
+		},
+		{
+			name:     "OtherUser",
+			username: "otheruser",
+			body:     gin.H{"current_password": password, "new_password": newPassword},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "WrongCurrentPassword",
+			username: user.Username,
+			body:     gin.H{"current_password": "wrong_password", "new_password": newPassword},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "WeakNewPassword",
+			username: user.Username,
+			body:     gin.H{"current_password": password, "new_password": "short"},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertPasswordFieldErrors(t, recorder, "new_password", util.ErrPasswordTooShort)
+			},
+		},
+		{
+			name:     "SamePassword",
+			username: user.Username,
+			body:     gin.H{"current_password": password, "new_password": password},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "InternalError",
+			username: user.Username,
+			body:     gin.H{"current_password": password, "new_password": newPassword},
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthChecks(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			url := fmt.Sprintf("/users/%s/password", tc.username)
+			req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			tc.setupAuth(t, req, server.tokenMaker)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestChangePasswordInvalidatesTokens(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ListRevokedTokensSince(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return([]db.RevokedToken{}, nil)
+	store.EXPECT().
+		GetUserAuthState(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1)
+	store.EXPECT().
+		GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		UpdateUserPassword(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.UpdateUserPasswordParams) (db.User, error) {
+			updated := user
+			updated.HashedPassword = arg.HashedPassword
+			updated.PasswordChangedAt = arg.PasswordChangedAt
+			return updated, nil
+		})
+
+	server := newTestServer(t, store)
+	accessToken, _, err := server.tokenMaker.CreateToken(token.TypeAccess, user.Username, util.RoleUser, util.RoleScopes(util.RoleUser), time.Minute)
+	assert.NoError(t, err)
+
+	body, err := json.Marshal(gin.H{"current_password": password, "new_password": util.RandomString(12)})
+	assert.NoError(t, err)
+
+	// the second request is rejected without reading the database again
+	for _, status := range []int{http.StatusOK, http.StatusUnauthorized} {
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/users/%s/password", user.Username)
+		req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set(authHeaderKey, fmt.Sprintf("%s %s", authTypeBearer, accessToken))
+
+		server.router.ServeHTTP(recorder, req)
+		assert.Equal(t, status, recorder.Code)
+	}
+}
+
+// testNotifier keeps sent messages in memory.
+type testNotifier struct {
+	messages []notify.Message
+	err      error
+}
+
+func (n *testNotifier) Notify(_ context.Context, msg notify.Message) error {
+	n.messages = append(n.messages, msg)
+	return n.err
+}
+
+func TestForgotPasswordAPI(t *testing.T) {
+	user := randomUser()
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		notifyErr     error
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"username": user.Username},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountPasswordResetTokensSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CountPasswordResetTokensSinceParams) (db.CountPasswordResetTokensSinceRow, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						assert.WithinDuration(t, time.Now().Add(-verificationLimitWindow), arg.Since.Time, time.Second)
+						return db.CountPasswordResetTokensSinceRow{}, nil
+					})
+				store.EXPECT().
+					CreatePasswordResetToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreatePasswordResetTokenParams) (db.PasswordResetToken, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						assert.Len(t, arg.TokenHash, 64)
+						assert.WithinDuration(t, time.Now().Add(30*time.Minute), arg.ExpiresAt.Time, time.Second)
+						return db.PasswordResetToken{UserID: arg.UserID, TokenHash: arg.TokenHash}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+				assert.Len(t, notifier.messages, 1)
+				assert.Equal(t, user.Email, notifier.messages[0].To)
+			},
+		},
+		{
+			name: "TooSoon",
+			body: gin.H{"username": user.Username},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountPasswordResetTokensSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CountPasswordResetTokensSinceRow{
+						Sent:        1,
+						FirstSentAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Second), Valid: true},
+						LastSentAt:  pgtype.Timestamptz{Time: time.Now().Add(-time.Second), Valid: true},
+					}, nil)
+				store.EXPECT().
+					CreatePasswordResetToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				// the same answer, so the limit does not tell that the user exists
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name: "DailyLimit",
+			body: gin.H{"username": user.Username},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountPasswordResetTokensSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CountPasswordResetTokensSinceRow{
+						Sent:        int64(DefaultVerificationLimits.DailyLimit),
+						FirstSentAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+						LastSentAt:  pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+					}, nil)
+				store.EXPECT().
+					CreatePasswordResetToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name: "UnknownUser",
+			body: gin.H{"username": user.Username},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreatePasswordResetToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name:      "NotifierError",
+			body:      gin.H{"username": user.Username},
+			notifyErr: errors.New("smtp is down"),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountPasswordResetTokensSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CountPasswordResetTokensSinceRow{}, nil)
+				store.EXPECT().
+					CreatePasswordResetToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PasswordResetToken{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"username": user.Username},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountPasswordResetTokensSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CountPasswordResetTokensSinceRow{}, nil)
+				store.EXPECT().
+					CreatePasswordResetToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PasswordResetToken{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name: "InvalidRequest",
+			body: gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			notifier := &testNotifier{err: tc.notifyErr}
+			server := newTestServer(t, store, WithNotifier(notifier))
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/password/forgot", bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder, notifier)
+		})
+	}
+}
+
+func TestResetPasswordAPI(t *testing.T) {
+	user := randomUser()
+	resetToken := util.RandomString(43)
+	newPassword := util.RandomString(12)
+	storedToken := db.PasswordResetToken{
+		ID:        util.RandomInt(1, 1000),
+		UserID:    user.ID,
+		TokenHash: util.HashToken(resetToken),
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"token": resetToken, "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPasswordResetToken(gomock.Any(), gomock.Eq(storedToken.TokenHash)).
+					Times(1).
+					Return(storedToken, nil)
+				store.EXPECT().
+					GetUserByID(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.ResetPasswordTxParams) (db.ResetPasswordTxResult, error) {
+						assert.Equal(t, storedToken.ID, arg.ResetTokenID)
+						assert.NoError(t, util.CheckPassword(arg.HashedPassword, newPassword))
+						return db.ResetPasswordTxResult{User: user}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assertBodyMatchUser(t, recorder.Body, user)
+			},
+		},
+		{
+			name: "InvalidToken",
+			body: gin.H{"token": resetToken, "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPasswordResetToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PasswordResetToken{}, sql.ErrNoRows)
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "TokenUsedConcurrently",
+			body: gin.H{"token": resetToken, "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPasswordResetToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(storedToken, nil)
+				store.EXPECT().
+					GetUserByID(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ResetPasswordTxResult{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "WeakPassword",
+			body: gin.H{"token": resetToken, "new_password": "short"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPasswordResetToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(storedToken, nil)
+				store.EXPECT().
+					GetUserByID(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertPasswordFieldErrors(t, recorder, "new_password", util.ErrPasswordTooShort)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{"token": resetToken, "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPasswordResetToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PasswordResetToken{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/password/reset", bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginRehash(t *testing.T) {
+	user := randomUser()
+	password := util.RandomString(8)
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+	user.HashedPassword = string(bcryptHash)
+
+	hasher := util.NewArgon2idHasher(util.DefaultArgon2idParams)
+	rehashed := make(chan db.RehashUserPasswordParams, 1)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	stubLoginThrottle(store)
+	store.EXPECT().
+		GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		GetUserTotp(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.UserTotp{}, sql.ErrNoRows)
+	store.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.Session{}, nil)
+	store.EXPECT().
+		RehashUserPassword(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.RehashUserPasswordParams) error {
+			rehashed <- arg
+			return nil
+		})
+
+	server := newTestServer(t, store, WithPasswordHasher(hasher))
+	recorder := httptest.NewRecorder()
+
+	body, err := json.Marshal(gin.H{"identifier": user.Username, "password": password})
+	assert.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
+	assert.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	select {
+	case arg := <-rehashed:
+		assert.Equal(t, user.ID, arg.ID)
+		assert.Equal(t, user.HashedPassword, arg.OldHashedPassword)
+		assert.NoError(t, hasher.Check(arg.NewHashedPassword, password))
+		assert.False(t, hasher.NeedsRehash(arg.NewHashedPassword))
+	case <-time.After(5 * time.Second):
+		t.Fatal("password was not rehashed")
+	}
+}
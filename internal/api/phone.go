@@ -0,0 +1,160 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/notify"
+	"github.com/mauzec/user-api/internal/util"
+)
+
+const (
+	// phoneOTPDigits is the length of a phone verification code
+	phoneOTPDigits = 6
+	// phoneOTPMaxAttempts is how many times a user can try codes within a
+	// day, asking for a new code does not bring new attempts
+	phoneOTPMaxAttempts = 10
+	// minCodeHashKeySize is the least length of the key codes are hashed with
+	minCodeHashKeySize = 32
+)
+
+var (
+	ErrInvalidOTP         = errors.New("verification code is invalid or expired")
+	ErrTooManyOTPAttempts = errors.New("too many attempts, try again later")
+)
+
+type requestPhoneVerificationResponse struct {
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// requestPhoneVerification sends a one-time code to the phone of the user.
+// Codes sent earlier stop working. Every text message costs, so codes are
+// limited by the verification limits, see WithVerificationLimits.
+func (server *Server) requestPhoneVerification(ctx *gin.Context) {
+	user, ok := server.getOwnUser(ctx)
+	if !ok {
+		return
+	}
+	if user.PhoneVerifiedAt.Valid {
+		ctx.JSON(http.StatusConflict, errorResponse(errors.New("phone is already verified")))
+		return
+	}
+
+	sent, err := server.store.CountPhoneVerificationsSince(ctx, db.CountPhoneVerificationsSinceParams{
+		UserID: user.ID,
+		Since:  server.verificationLimits.since(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	if wait := server.verificationLimits.wait(sent.Sent, sent.FirstSentAt, sent.LastSentAt); wait > 0 {
+		setRetryAfter(ctx, wait)
+		ctx.JSON(http.StatusTooManyRequests, errorResponse(ErrVerificationRateLimited))
+		return
+	}
+
+	code, err := util.NewOTP(phoneOTPDigits)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	expiresAt := time.Now().Add(server.tokenParams.PhoneVerificationDuration)
+	_, err = server.store.CreatePhoneVerification(ctx, db.CreatePhoneVerificationParams{
+		UserID:    user.ID,
+		Phone:     user.Phone,
+		CodeHash:  util.HashCode(server.codeHashKey, code),
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	err = server.smsSender.SendSMS(ctx, notify.SMS{
+		To:   user.Phone,
+		Body: fmt.Sprintf("Your verification code is %s", code),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, requestPhoneVerificationResponse{ExpiresAt: expiresAt})
+}
+
+type confirmPhoneVerificationRequest struct {
+	Code string `json:"code" binding:"required,numeric"`
+}
+
+// confirmPhoneVerification checks the last code sent to the user and
+// marks the phone as verified. Every check counts as an attempt of the user.
+func (server *Server) confirmPhoneVerification(ctx *gin.Context) {
+	var req confirmPhoneVerificationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	user, ok := server.getOwnUser(ctx)
+	if !ok {
+		return
+	}
+
+	verification, err := server.store.GetActivePhoneVerification(ctx, db.GetActivePhoneVerificationParams{
+		UserID: user.ID,
+		Phone:  user.Phone,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidOTP))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	// the attempt is counted before the check, so parallel guesses can't
+	// exceed the limit by much; attempts at earlier codes count too
+	verification, err = server.store.IncrementPhoneVerificationAttempts(ctx, db.IncrementPhoneVerificationAttemptsParams{
+		ID:          verification.ID,
+		Since:       server.verificationLimits.since(),
+		MaxAttempts: phoneOTPMaxAttempts,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusTooManyRequests, errorResponse(ErrTooManyOTPAttempts))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	codeHash := util.HashCode(server.codeHashKey, req.Code)
+	if subtle.ConstantTimeCompare([]byte(codeHash), []byte(verification.CodeHash)) != 1 {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidOTP))
+		return
+	}
+
+	result, err := server.store.VerifyPhoneTx(ctx, db.VerifyPhoneTxParams{
+		PhoneVerificationID: verification.ID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidOTP))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(result.User))
+}
@@ -0,0 +1,412 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/notify"
+	"github.com/mauzec/user-api/internal/token"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+// testSMSSender keeps sent text messages in memory.
+type testSMSSender struct {
+	messages []notify.SMS
+}
+
+func (s *testSMSSender) SendSMS(_ context.Context, sms notify.SMS) error {
+	s.messages = append(s.messages, sms)
+	return nil
+}
+
+const testCodeHashKey = "abcdefghijklmnopqrstuvwxyz123456"
+
+func TestRequestPhoneVerificationAPI(t *testing.T) {
+	user := randomUser()
+	var codeHash string
+
+	verified := user
+	verified.PhoneVerifiedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	testCases := []struct {
+		name          string
+		username      string
+		setupAuth     func(t *testing.T, req *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, sender *testSMSSender)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountPhoneVerificationsSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CountPhoneVerificationsSinceParams) (db.CountPhoneVerificationsSinceRow, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						assert.WithinDuration(t, time.Now().Add(-verificationLimitWindow), arg.Since.Time, time.Second)
+						return db.CountPhoneVerificationsSinceRow{}, nil
+					})
+				store.EXPECT().
+					CreatePhoneVerification(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreatePhoneVerificationParams) (db.PhoneVerification, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						assert.Equal(t, user.Phone, arg.Phone)
+						assert.WithinDuration(t, time.Now().Add(10*time.Minute), arg.ExpiresAt.Time, time.Second)
+						codeHash = arg.CodeHash
+						return db.PhoneVerification{UserID: arg.UserID, Phone: arg.Phone, CodeHash: arg.CodeHash}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *testSMSSender) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+				assert.Len(t, sender.messages, 1)
+				assert.Equal(t, user.Phone, sender.messages[0].To)
+
+				// a plain sha256 of six digits is found by trying them all
+				code := regexp.MustCompile(`[0-9]{6}`).FindString(sender.messages[0].Body)
+				assert.NotEmpty(t, code)
+				assert.Equal(t, util.HashCode([]byte(testCodeHashKey), code), codeHash)
+				assert.NotEqual(t, util.HashToken(code), codeHash)
+			},
+		},
+		{
+			name:     "TooSoon",
+			username: user.Username,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountPhoneVerificationsSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CountPhoneVerificationsSinceRow{
+						Sent:        1,
+						FirstSentAt: pgtype.Timestamptz{Time: time.Now().Add(-30 * time.Second), Valid: true},
+						LastSentAt:  pgtype.Timestamptz{Time: time.Now().Add(-30 * time.Second), Valid: true},
+					}, nil)
+				store.EXPECT().
+					CreatePhoneVerification(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *testSMSSender) {
+				assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				assert.Equal(t, "30", recorder.Header().Get("Retry-After"))
+				assert.Empty(t, sender.messages)
+			},
+		},
+		{
+			name:     "DailyLimit",
+			username: user.Username,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountPhoneVerificationsSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CountPhoneVerificationsSinceRow{
+						Sent:        int64(DefaultVerificationLimits.DailyLimit),
+						FirstSentAt: pgtype.Timestamptz{Time: time.Now().Add(-23 * time.Hour), Valid: true},
+						LastSentAt:  pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+					}, nil)
+				store.EXPECT().
+					CreatePhoneVerification(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *testSMSSender) {
+				// until the first code of the day is a day old
+				assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				assert.Equal(t, "3600", recorder.Header().Get("Retry-After"))
+				assert.Empty(t, sender.messages)
+			},
+		},
+		{
+			name:     "AlreadyVerified",
+			username: user.Username,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(verified, nil)
+				store.EXPECT().
+					CreatePhoneVerification(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *testSMSSender) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+				assert.Empty(t, sender.messages)
+			},
+		},
+		{
+			name:     "OtherUser",
+			username: "otheruser",
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreatePhoneVerification(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *testSMSSender) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "InternalError",
+			username: user.Username,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addAuthHeader(t, req, tokenMaker, authTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CountPhoneVerificationsSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CountPhoneVerificationsSinceRow{}, nil)
+				store.EXPECT().
+					CreatePhoneVerification(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PhoneVerification{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, sender *testSMSSender) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+				assert.Empty(t, sender.messages)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthChecks(store)
+			tc.buildStubs(store)
+
+			sender := &testSMSSender{}
+			server := newTestServer(t, store, WithSMSSender(sender), WithCodeHashKey(testCodeHashKey))
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/users/%s/verify_phone/request", tc.username)
+			req, err := http.NewRequest(http.MethodPost, url, nil)
+			assert.NoError(t, err)
+
+			tc.setupAuth(t, req, server.tokenMaker)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder, sender)
+		})
+	}
+}
+
+func TestConfirmPhoneVerificationAPI(t *testing.T) {
+	user := randomUser()
+	code := "123456"
+
+	verification := db.PhoneVerification{
+		ID:       util.RandomInt(1, 1000),
+		UserID:   user.ID,
+		Phone:    user.Phone,
+		CodeHash: util.HashCode([]byte(testCodeHashKey), code),
+	}
+	attempted := verification
+	attempted.Attempts = 1
+
+	verified := user
+	verified.PhoneVerifiedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetActivePhoneVerification(gomock.Any(), gomock.Eq(db.GetActivePhoneVerificationParams{
+						UserID: user.ID,
+						Phone:  user.Phone,
+					})).
+					Times(1).
+					Return(verification, nil)
+				store.EXPECT().
+					IncrementPhoneVerificationAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.IncrementPhoneVerificationAttemptsParams) (db.PhoneVerification, error) {
+						assert.Equal(t, verification.ID, arg.ID)
+						assert.WithinDuration(t, time.Now().Add(-verificationLimitWindow), arg.Since.Time, time.Second)
+						assert.Equal(t, int32(phoneOTPMaxAttempts), arg.MaxAttempts)
+						return attempted, nil
+					})
+				store.EXPECT().
+					VerifyPhoneTx(gomock.Any(), gomock.Eq(db.VerifyPhoneTxParams{PhoneVerificationID: verification.ID})).
+					Times(1).
+					Return(db.VerifyPhoneTxResult{User: verified}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assertBodyMatchUser(t, recorder.Body, verified)
+			},
+		},
+		{
+			name: "WrongCode",
+			body: gin.H{"code": "654321"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetActivePhoneVerification(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(verification, nil)
+				store.EXPECT().
+					IncrementPhoneVerificationAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(attempted, nil)
+				store.EXPECT().
+					VerifyPhoneTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "TooManyAttempts",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetActivePhoneVerification(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(verification, nil)
+				store.EXPECT().
+					IncrementPhoneVerificationAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PhoneVerification{}, sql.ErrNoRows)
+				store.EXPECT().
+					VerifyPhoneTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+			},
+		},
+		{
+			name: "NoActiveCode",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetActivePhoneVerification(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PhoneVerification{}, sql.ErrNoRows)
+				store.EXPECT().
+					IncrementPhoneVerificationAttempts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NotNumeric",
+			body: gin.H{"code": "abcdef"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthChecks(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store, WithCodeHashKey(testCodeHashKey))
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			url := fmt.Sprintf("/users/%s/verify_phone/confirm", user.Username)
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			addAuthHeader(t, req, server.tokenMaker, authTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestShortCodeHashKey(t *testing.T) {
+	tokenMaker, err := token.NewPasetoSMaker("12345678901234567890123456789012")
+	assert.NoError(t, err)
+
+	server, err := NewServer(nil, tokenMaker, TokenParams{}, WithCodeHashKey(util.RandomString(31)))
+	assert.Error(t, err)
+	assert.Nil(t, server)
+}
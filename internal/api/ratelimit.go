@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idle buckets are swept once the in-memory limiter holds this many
+const memoryRateLimiterSweepSize = 10000
+
+var ErrRateLimited = errors.New("too many requests, try again later")
+
+// RateLimit lets a client make Requests requests in a burst, then one more
+// every Period / Requests. A zero limit does not limit anything.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (limit RateLimit) enabled() bool {
+	return limit.Requests > 0 && limit.Period > 0
+}
+
+// perSecond returns how many requests are refilled each second.
+func (limit RateLimit) perSecond() float64 {
+	return float64(limit.Requests) / limit.Period.Seconds()
+}
+
+// ParseRateLimit parses a limit written as "requests/period", e.g. "10/1m".
+// An empty string or "0" is no limit.
+func ParseRateLimit(s string) (RateLimit, error) {
+	if s == "" || s == "0" {
+		return RateLimit{}, nil
+	}
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("rate limit %q is not requests/period", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 0 {
+		return RateLimit{}, fmt.Errorf("invalid number of requests in rate limit %q", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid period in rate limit %q", s)
+	}
+	return RateLimit{Requests: n, Period: d}, nil
+}
+
+// RateLimits sets the limit of each route group.
+type RateLimits struct {
+	// POST /users, per client ip
+	Signup RateLimit
+	// logins, token renewal, password resets and the other public routes, per client ip
+	Login RateLimit
+	// authenticated reads and writes, per API key or user
+	Read  RateLimit
+	Write RateLimit
+	// /admin routes, per API key or user
+	Admin RateLimit
+}
+
+// DefaultRateLimits are used unless set by WithRateLimits.
+var DefaultRateLimits = RateLimits{
+	Signup: RateLimit{Requests: 20, Period: time.Hour},
+	Login:  RateLimit{Requests: 10, Period: time.Minute},
+	Read:   RateLimit{Requests: 300, Period: time.Minute},
+	Write:  RateLimit{Requests: 60, Period: time.Minute},
+	Admin:  RateLimit{Requests: 120, Period: time.Minute},
+}
+
+// RateLimitResult is the state of a bucket after taking a request from it.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// how long until the next request is allowed, if this one was not
+	RetryAfter time.Duration
+	// how long until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// RateLimiter keeps token buckets. Instances sharing a limiter share the
+// limits, so a limiter backed by a shared store limits a whole deployment.
+type RateLimiter interface {
+	// Take takes a request from the bucket of the key, unless it is empty.
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// when the bucket is full again, after that it can be dropped
+	fullAt time.Time
+}
+
+// MemoryRateLimiter keeps the buckets in memory, so each instance limits on its own.
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+func (l *MemoryRateLimiter) Take(_ context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	now := time.Now()
+	capacity := float64(limit.Requests)
+	rate := limit.perSecond()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= memoryRateLimiterSweepSize {
+			l.sweep(now)
+		}
+		b = &tokenBucket{tokens: capacity, updatedAt: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updatedAt).Seconds()*rate)
+	b.updatedAt = now
+
+	var result RateLimitResult
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = secondsDuration((capacity - b.tokens) / rate)
+	b.fullAt = now.Add(result.ResetAfter)
+	return result, nil
+}
+
+// sweep drops the buckets which have filled up, they are the same as new ones.
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if !now.Before(b.fullAt) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// rateLimitKey returns whose bucket a request takes from.
+type rateLimitKey func(ctx *gin.Context) string
+
+func rateLimitByIP(ctx *gin.Context) string {
+	return "ip:" + ctx.ClientIP()
+}
+
+// rateLimitByCaller keys requests by the API key they were made with or by
+// the user of the token. It must run after authMiddleware.
+func rateLimitByCaller(ctx *gin.Context) string {
+	payload, err := getAuthPayload(ctx)
+	if err != nil {
+		return rateLimitByIP(ctx)
+	}
+	if getAuthType(ctx) == authTypeAPIKey {
+		return "apikey:" + payload.ID.String()
+	}
+	return "user:" + payload.Username
+}
+
+// rateLimitMiddleware limits the requests of the route group. Every group
+// has its own buckets. The RateLimit-* headers tell clients how much of the
+// limit is left. If the limiter fails, requests are let through.
+func rateLimitMiddleware(limiter RateLimiter, group string, limit RateLimit, key rateLimitKey) gin.HandlerFunc {
+	if !limit.enabled() {
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(math.Ceil(limit.Period.Seconds())))
+	return func(ctx *gin.Context) {
+		result, err := limiter.Take(ctx, group+":"+key(ctx), limit)
+		if err != nil {
+			log.Println("unable to check rate limit:", err)
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("RateLimit-Policy", policy)
+		ctx.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		ctx.Header("RateLimit-Reset", strconv.Itoa(int(math.Ceil(result.ResetAfter.Seconds()))))
+		if !result.Allowed {
+			setRetryAfter(ctx, result.RetryAfter)
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse(ErrRateLimited))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// rateLimit limits the requests of a route group with the limiter of the server.
+func (server *Server) rateLimit(group string, limit RateLimit, key rateLimitKey) gin.HandlerFunc {
+	return rateLimitMiddleware(server.rateLimiter, group, limit, key)
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/token"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	testCases := []struct {
+		in      string
+		want    RateLimit
+		wantErr bool
+	}{
+		{in: "10/1m", want: RateLimit{Requests: 10, Period: time.Minute}},
+		{in: "300/30s", want: RateLimit{Requests: 300, Period: 30 * time.Second}},
+		{in: "", want: RateLimit{}},
+		{in: "0", want: RateLimit{}},
+		{in: "10", wantErr: true},
+		{in: "ten/1m", wantErr: true},
+		{in: "-1/1m", wantErr: true},
+		{in: "10/0s", wantErr: true},
+		{in: "10/minute", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseRateLimit(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	limiter := NewMemoryRateLimiter()
+	limit := RateLimit{Requests: 3, Period: 3 * time.Second}
+
+	for i := 2; i >= 0; i-- {
+		result, err := limiter.Take(context.Background(), "a", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+		assert.Zero(t, result.RetryAfter)
+	}
+
+	result, err := limiter.Take(context.Background(), "a", limit)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Zero(t, result.Remaining)
+	assert.InDelta(t, time.Second, result.RetryAfter, float64(50*time.Millisecond))
+	assert.InDelta(t, 3*time.Second, result.ResetAfter, float64(50*time.Millisecond))
+
+	// other keys have their own buckets
+	result, err = limiter.Take(context.Background(), "b", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// one request is refilled every second
+	limiter.buckets["a"].updatedAt = limiter.buckets["a"].updatedAt.Add(-time.Second)
+	result, err = limiter.Take(context.Background(), "a", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Zero(t, result.Remaining)
+}
+
+func TestMemoryRateLimiterSweep(t *testing.T) {
+	limiter := NewMemoryRateLimiter()
+	limit := RateLimit{Requests: 1, Period: time.Hour}
+
+	_, err := limiter.Take(context.Background(), "full", limit)
+	assert.NoError(t, err)
+	_, err = limiter.Take(context.Background(), "empty", limit)
+	assert.NoError(t, err)
+	limiter.buckets["full"].fullAt = time.Now().Add(-time.Second)
+
+	limiter.sweep(time.Now())
+	assert.NotContains(t, limiter.buckets, "full")
+	assert.Contains(t, limiter.buckets, "empty")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limits := RateLimits{
+		Login: RateLimit{Requests: 2, Period: time.Minute},
+		Read:  RateLimit{Requests: 1, Period: time.Minute},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	stubAuthChecks(store)
+	store.EXPECT().
+		GetUserByUsername(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(db.User{Status: userStatusActive}, nil)
+
+	server := newTestServer(t, store, WithRateLimits(limits))
+
+	login := func(ip string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/users/login", nil)
+		assert.NoError(t, err)
+		req.RemoteAddr = ip + ":54321"
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+	getUser := func(username string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/users/"+username, nil)
+		assert.NoError(t, err)
+		addAuthHeader(t, req, server.tokenMaker, authTypeBearer, username, time.Minute)
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// the requests are invalid, but still count
+	recorder := login("203.0.113.7")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "2;w=60", recorder.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, "2", recorder.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", recorder.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", recorder.Header().Get("RateLimit-Reset"))
+
+	recorder = login("203.0.113.7")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "0", recorder.Header().Get("RateLimit-Remaining"))
+
+	recorder = login("203.0.113.7")
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "0", recorder.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", recorder.Header().Get("Retry-After"))
+
+	// another client is not limited
+	recorder = login("198.51.100.1")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	// authenticated routes are limited per user, not per ip
+	assert.Equal(t, http.StatusOK, getUser("alice").Code)
+	assert.Equal(t, http.StatusTooManyRequests, getUser("alice").Code)
+	assert.Equal(t, http.StatusOK, getUser("bob").Code)
+
+	// groups without a limit are not limited and carry no headers
+	req, err := http.NewRequest(http.MethodPost, "/users", nil)
+	assert.NoError(t, err)
+	for range 3 {
+		recorder = httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimitByCaller(t *testing.T) {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx.Request.RemoteAddr = "203.0.113.7:54321"
+	assert.Equal(t, "ip:203.0.113.7", rateLimitByCaller(ctx))
+
+	tokenMaker := newTestServer(t, nil).tokenMaker
+	_, payload, err := tokenMaker.CreateToken(token.TypeAccess, "alice", "user", nil, time.Minute)
+	assert.NoError(t, err)
+
+	ctx.Set(authPayloadKey, payload)
+	ctx.Set(authTypeKey, authTypeBearer)
+	assert.Equal(t, "user:alice", rateLimitByCaller(ctx))
+
+	ctx.Set(authTypeKey, authTypeAPIKey)
+	assert.Equal(t, "apikey:"+payload.ID.String(), rateLimitByCaller(ctx))
+}
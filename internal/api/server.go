@@ -1,19 +1,28 @@
 package api
 
 import (
+	"crypto/rand"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/notify"
 	"github.com/mauzec/user-api/internal/token"
+	"github.com/mauzec/user-api/internal/util"
 )
 
 type TokenParams struct {
-	AccessTokenDuration time.Duration
-	// RefreshTokenDuration time.Duration
+	AccessTokenDuration        time.Duration
+	RefreshTokenDuration       time.Duration
+	PasswordResetTokenDuration time.Duration
+	EmailVerificationDuration  time.Duration
+	PhoneVerificationDuration  time.Duration
+	// how long the mfa_pending token of a 2FA login is valid
+	MfaTokenDuration time.Duration
 }
 
 type Server struct {
@@ -22,10 +31,167 @@ type Server struct {
 
 	tokenMaker  token.Maker
 	tokenParams TokenParams
+	denylist    *tokenDenylist
+	userStates  *userStateCache
+
+	passwordHasher util.PasswordHasher
+	passwordPolicy util.PasswordPolicy
+	// checked against for unknown users, made on the first use
+	dummyHash     string
+	dummyHashOnce sync.Once
+	loginThrottle *loginThrottle
+	rateLimiter   RateLimiter
+	rateLimits    RateLimits
+
+	notifier  notify.Notifier
+	smsSender notify.SMSSender
+	// key of the stored hashes of phone verification codes
+	codeHashKey []byte
+	// whether users with unverified emails may log in
+	allowUnverifiedLogin bool
+	// whether signups answer the same for taken usernames and emails
+	privateSignup bool
+	// how often verification codes can be sent to a user
+	verificationLimits VerificationLimits
+	// how long deleted users can be restored before they are purged
+	deletionGracePeriod time.Duration
+}
+
+// Option sets an optional dependency of the Server.
+type Option func(*Server)
+
+// WithNotifier sets where password reset tokens and email verification
+// codes are sent. By default they are logged.
+func WithNotifier(notifier notify.Notifier) Option {
+	return func(server *Server) {
+		server.notifier = notifier
+	}
 }
 
-func NewServer(store db.Store, tokenMaker token.Maker, tokenParams TokenParams) (*Server, error) {
-	server := &Server{store: store, tokenMaker: tokenMaker, tokenParams: tokenParams}
+// WithSMSSender sets where phone verification codes are sent. By default they are logged.
+func WithSMSSender(sender notify.SMSSender) Option {
+	return func(server *Server) {
+		server.smsSender = sender
+	}
+}
+
+// WithCodeHashKey sets the key phone verification codes are hashed with
+// before they are stored, it must be at least 32 bytes long. By default a
+// random key is made, so a code sent by one instance can not be confirmed
+// by another or after a restart.
+func WithCodeHashKey(key string) Option {
+	return func(server *Server) {
+		server.codeHashKey = []byte(key)
+	}
+}
+
+// WithUnverifiedLogin lets users log in before verifying their emails.
+// By default they are rejected until verification.
+func WithUnverifiedLogin(allow bool) Option {
+	return func(server *Server) {
+		server.allowUnverifiedLogin = allow
+	}
+}
+
+// WithPrivateSignup makes signups answer the same whether the username and
+// the email are taken or not, so they cannot be used to find users. Taken
+// ones are told through the email instead. By default signup answers 409.
+func WithPrivateSignup(private bool) Option {
+	return func(server *Server) {
+		server.privateSignup = private
+	}
+}
+
+// WithVerificationLimits sets how often verification codes can be sent to
+// a user. By default DefaultVerificationLimits are used.
+func WithVerificationLimits(limits VerificationLimits) Option {
+	return func(server *Server) {
+		server.verificationLimits = limits
+	}
+}
+
+// WithDeletionGracePeriod sets how long deleted users can be restored
+// before they are purged. By default, or if the period is not positive,
+// it is 30 days.
+func WithDeletionGracePeriod(period time.Duration) Option {
+	return func(server *Server) {
+		if period > 0 {
+			server.deletionGracePeriod = period
+		}
+	}
+}
+
+// WithLoginThrottle sets how failed logins are slowed down and when users
+// are locked out. By default DefaultLoginThrottleParams are used.
+func WithLoginThrottle(params LoginThrottleParams) Option {
+	return func(server *Server) {
+		server.loginThrottle = newLoginThrottle(server.store, params)
+	}
+}
+
+// WithPasswordHasher sets how passwords are hashed. By default they are
+// hashed with Argon2id and DefaultArgon2idParams.
+func WithPasswordHasher(hasher util.PasswordHasher) Option {
+	return func(server *Server) {
+		server.passwordHasher = hasher
+	}
+}
+
+// WithPasswordPolicy sets what new passwords must meet. By default
+// util.DefaultPasswordPolicy is used.
+func WithPasswordPolicy(policy util.PasswordPolicy) Option {
+	return func(server *Server) {
+		server.passwordPolicy = policy
+	}
+}
+
+// WithRateLimiter sets where the rate limit buckets are kept. By default
+// they are kept in memory, so each instance limits on its own.
+func WithRateLimiter(limiter RateLimiter) Option {
+	return func(server *Server) {
+		server.rateLimiter = limiter
+	}
+}
+
+// WithRateLimits sets the limits of the route groups. By default
+// DefaultRateLimits are used.
+func WithRateLimits(limits RateLimits) Option {
+	return func(server *Server) {
+		server.rateLimits = limits
+	}
+}
+
+func NewServer(store db.Store, tokenMaker token.Maker, tokenParams TokenParams, opts ...Option) (*Server, error) {
+	server := &Server{
+		store:       store,
+		tokenMaker:  tokenMaker,
+		tokenParams: tokenParams,
+		denylist:    newTokenDenylist(store),
+		userStates:  newUserStateCache(store),
+		notifier:    notify.NewLogNotifier(),
+		smsSender:   notify.NewLogSMSSender(),
+
+		passwordHasher: util.NewArgon2idHasher(util.DefaultArgon2idParams),
+		passwordPolicy: util.DefaultPasswordPolicy,
+		loginThrottle:  newLoginThrottle(store, DefaultLoginThrottleParams),
+		rateLimiter:    NewMemoryRateLimiter(),
+		rateLimits:     DefaultRateLimits,
+
+		verificationLimits:  DefaultVerificationLimits,
+		deletionGracePeriod: defaultDeletionGracePeriod,
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	if len(server.codeHashKey) == 0 {
+		server.codeHashKey = make([]byte, minCodeHashKeySize)
+		if _, err := rand.Read(server.codeHashKey); err != nil {
+			return nil, fmt.Errorf("failed to make code hash key: %w", err)
+		}
+	} else if len(server.codeHashKey) < minCodeHashKeySize {
+		return nil, fmt.Errorf("code hash key size must be at least %v, got %v", minCodeHashKeySize, len(server.codeHashKey))
+	}
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		if err := v.RegisterValidation("phone", validPhone); err != nil {
@@ -34,6 +200,9 @@ func NewServer(store db.Store, tokenMaker token.Maker, tokenParams TokenParams)
 		if err := v.RegisterValidation("gender", validGender); err != nil {
 			return nil, fmt.Errorf("failed to register gender validation: %w", err)
 		}
+		if err := v.RegisterValidation("identifier", validIdentifier); err != nil {
+			return nil, fmt.Errorf("failed to register identifier validation: %w", err)
+		}
 	}
 
 	server.SetupRouter()
@@ -44,15 +213,77 @@ func (server *Server) SetupRouter() {
 	router := gin.Default()
 
 	_ = router.SetTrustedProxies(nil)
+	router.Use(normalizeUsernameParam())
+
+	router.POST("/users", server.rateLimit("signup", server.rateLimits.Signup, rateLimitByIP), server.createUser)
+
+	publicRoutes := router.Group("/").Use(server.rateLimit("login", server.rateLimits.Login, rateLimitByIP))
 
-	router.POST("/users", server.createUser)
-	router.POST("/users/login", server.loginUser)
+	publicRoutes.POST("/users/login", server.loginUser)
+	publicRoutes.POST("/users/login/mfa", server.loginMfa)
+	publicRoutes.POST("/tokens/renew_access", server.renewAccessToken)
+	publicRoutes.POST("/password/forgot", server.forgotPassword)
+	publicRoutes.POST("/password/reset", server.resetPassword)
+	publicRoutes.POST("/users/:username/verify_email", server.verifyEmail)
+	publicRoutes.POST("/users/:username/verify_email/resend", server.resendEmailVerification)
+	publicRoutes.POST("/users/restore", server.restoreUser)
 
-	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker))
+	// only makers with asymmetric keys can share them with other services
+	if _, ok := server.tokenMaker.(token.PublicKeyMaker); ok {
+		router.GET("/.well-known/paseto-public-key", server.getTokenPublicKey)
+	}
+
+	authGroup := router.Group("/", authMiddleware(
+		server.tokenMaker,
+		server.resolveAPIKey,
+		server.denylist.check,
+		server.userStates.check,
+	))
+	readRoutes := authGroup.Group("/", server.rateLimit("read", server.rateLimits.Read, rateLimitByCaller))
+	writeRoutes := authGroup.Group("/", server.rateLimit("write", server.rateLimits.Write, rateLimitByCaller))
+
+	writeRoutes.POST("/users/logout", requireBearer(), server.logoutUser)
+	writeRoutes.POST("/users/:username/sessions/revoke_all", requireBearer(), requireScope(util.ScopeUsersWrite), server.revokeAllSessions)
+
+	// lists for moderators and admins
+	readRoutes.GET("/users", requireRole(util.RoleModerator, util.RoleAdmin), requireScope(util.ScopeUsersRead), server.listUsers)
+	readRoutes.GET("/users/search", requireRole(util.RoleModerator, util.RoleAdmin), requireScope(util.ScopeUsersRead), server.searchUsers)
 
 	// single queries
-	authRoutes.GET("/users/:username", server.getUserByUsername)
-	authRoutes.POST("/users/:username", server.updateUser)
+	readRoutes.GET("/users/:username", requireScope(util.ScopeUsersRead), server.getUserByUsername)
+	writeRoutes.POST("/users/:username", requireBearer(), requireScope(util.ScopeUsersWrite), server.updateUser)
+	writeRoutes.DELETE("/users/:username", requireBearer(), requireScope(util.ScopeUsersWrite), server.deleteUser)
+	writeRoutes.PUT("/users/:username/password", requireBearer(), requireScope(util.ScopeUsersWrite), server.changePassword)
+	writeRoutes.POST("/users/:username/verify_phone/request", requireScope(util.ScopeUsersWrite), server.requestPhoneVerification)
+	writeRoutes.POST("/users/:username/verify_phone/confirm", requireScope(util.ScopeUsersWrite), server.confirmPhoneVerification)
+	writeRoutes.POST("/users/:username/totp", requireBearer(), requireScope(util.ScopeUsersWrite), server.enrollTotp)
+	writeRoutes.POST("/users/:username/totp/confirm", requireBearer(), requireScope(util.ScopeUsersWrite), server.confirmTotp)
+
+	// a leaked API key must not be able to create more keys
+	writeRoutes.POST("/users/:username/api_keys", requireBearer(), requireScope(util.ScopeUsersWrite), server.createAPIKey)
+	readRoutes.GET("/users/:username/api_keys", requireScope(util.ScopeUsersRead), server.listAPIKeys)
+	writeRoutes.DELETE("/users/:username/api_keys/:id", requireScope(util.ScopeUsersWrite), server.revokeAPIKey)
+
+	// back-office routes, admins can read, update and manage any user
+	adminRoutes := router.Group("/admin").Use(
+		authMiddleware(
+			server.tokenMaker,
+			server.resolveAPIKey,
+			server.denylist.check,
+			server.userStates.check,
+		),
+		server.rateLimit("admin", server.rateLimits.Admin, rateLimitByCaller),
+		requireRole(util.RoleAdmin),
+		requireScope(util.ScopeUsersAdmin),
+	)
+
+	adminRoutes.GET("/users/:username", server.getUserByUsername)
+	adminRoutes.POST("/users/:username", server.updateUser)
+	adminRoutes.DELETE("/users/:username", server.deleteUser)
+	adminRoutes.POST("/users/:username/suspend", server.suspendUser)
+	adminRoutes.POST("/users/:username/unsuspend", server.unsuspendUser)
+	adminRoutes.PUT("/users/:username/status", server.updateUserStatus)
+	adminRoutes.PUT("/users/:username/role", server.updateUserRole)
 
 	server.router = router
 }
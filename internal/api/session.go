@@ -0,0 +1,131 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/token"
+)
+
+func getAuthPayload(ctx *gin.Context) (*token.Payload, error) {
+	payloadVal, exists := ctx.Get(authPayloadKey)
+	if !exists {
+		return nil, ErrMissingAuthPayload
+	}
+	payload, ok := payloadVal.(*token.Payload)
+	if !ok {
+		return nil, ErrMissingAuthPayload
+	}
+	return payload, nil
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// logoutUser revokes the access token of the request and,
+// if a refresh token is given, blocks its session.
+func (server *Server) logoutUser(ctx *gin.Context) {
+	var req logoutRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+			return
+		}
+	}
+
+	payload, err := getAuthPayload(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if req.RefreshToken != "" {
+		refreshPayload, err := verifyToken(server.tokenMaker, req.RefreshToken, token.TypeRefresh)
+		if err != nil && !errors.Is(err, token.ErrExpiredToken) {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		// an expired refresh token can not be used anyway
+		if err == nil {
+			if refreshPayload.Username != payload.Username {
+				ctx.JSON(http.StatusUnauthorized, errorResponse(ErrPermissionDenied))
+				return
+			}
+
+			_, err = server.store.BlockSession(ctx, refreshPayload.ID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+				return
+			}
+			if err = server.denylist.revoke(ctx, refreshPayload); err != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+				return
+			}
+		}
+	}
+
+	if err = server.denylist.revoke(ctx, payload); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+type revokeAllSessionsResponse struct {
+	RevokedSessions int `json:"revoked_sessions"`
+}
+
+// revokeAllSessions blocks every session of the user and revokes their
+// refresh tokens together with the access token of the request. Access
+// tokens issued before are rejected too, by other instances once their
+// cached state of the user expires.
+func (server *Server) revokeAllSessions(ctx *gin.Context) {
+	var uri getUserUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	payload, err := getAuthPayload(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	if payload.Username != uri.Username {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(ErrPermissionDenied))
+		return
+	}
+
+	revoked, err := server.store.RevokeUserSessions(ctx, uri.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	for _, r := range revoked {
+		server.denylist.add(r.ID, r.ExpiresAt.Time)
+	}
+
+	// access tokens are not kept, they are rejected by when they were issued
+	user, err := server.store.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		Username:         uri.Username,
+		TokensValidAfter: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	server.userStates.set(user)
+
+	if err = server.denylist.revoke(ctx, payload); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, revokeAllSessionsResponse{RevokedSessions: len(revoked)})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+)
+
+const (
+	userStatusPendingVerification = "pending_verification"
+	userStatusActive              = "active"
+	userStatusSuspended           = "suspended"
+	userStatusBanned              = "banned"
+	userStatusLocked              = "locked"
+	userStatusDeleted             = "deleted"
+)
+
+var (
+	ErrUserSuspended = errors.New("user is suspended")
+	ErrUserBanned    = errors.New("user is banned")
+	ErrUserLocked    = errors.New("user is locked")
+)
+
+// userStatusTransitions lists the statuses a user can move to from each
+// status. Suspensions, bans and locks can be set again to change their
+// reason or expiry. Deleted users come back only through restoreUser.
+var userStatusTransitions = map[string][]string{
+	userStatusPendingVerification: {userStatusActive, userStatusSuspended, userStatusBanned, userStatusLocked, userStatusDeleted},
+	userStatusActive:              {userStatusSuspended, userStatusBanned, userStatusLocked, userStatusDeleted},
+	userStatusSuspended:           {userStatusActive, userStatusSuspended, userStatusBanned},
+	userStatusBanned:              {userStatusActive, userStatusBanned},
+	userStatusLocked:              {userStatusActive, userStatusSuspended, userStatusBanned, userStatusLocked, userStatusDeleted},
+	userStatusDeleted:             {userStatusActive, userStatusPendingVerification},
+}
+
+// canChangeStatus reports whether a user with the status from may be moved to the status to.
+func canChangeStatus(from, to string) bool {
+	return slices.Contains(userStatusTransitions[from], to)
+}
+
+func newStatusTransitionError(from, to string) error {
+	return fmt.Errorf("status of a %s user can not be changed to %s", from, to)
+}
+
+// isRestrictedStatus reports whether the status keeps the user out.
+// Only restrictions can be set with an expiry.
+func isRestrictedStatus(status string) bool {
+	return status == userStatusSuspended || status == userStatusBanned || status == userStatusLocked
+}
+
+// currentStatus returns the status in force. Restrictions lift once they
+// expire, bringing the user back to active or, if the email is not
+// verified yet, to pending verification.
+func currentStatus(status string, expiresAt, emailVerifiedAt pgtype.Timestamptz) string {
+	if !isRestrictedStatus(status) || !expiresAt.Valid || time.Now().Before(expiresAt.Time) {
+		return status
+	}
+	if !emailVerifiedAt.Valid {
+		return userStatusPendingVerification
+	}
+	return userStatusActive
+}
+
+func userCurrentStatus(user db.User) string {
+	return currentStatus(user.Status, user.StatusExpiresAt, user.EmailVerifiedAt)
+}
+
+// statusError returns the error for a status that keeps the user out, or nil.
+// Users pending verification are let in or not by the login itself.
+func statusError(status string) error {
+	switch status {
+	case userStatusSuspended:
+		return ErrUserSuspended
+	case userStatusBanned:
+		return ErrUserBanned
+	case userStatusLocked:
+		return ErrUserLocked
+	case userStatusDeleted:
+		return ErrNotFound
+	}
+	return nil
+}
+
+// statusErrorCode returns the http status code for an error of statusError.
+func statusErrorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrUserLocked):
+		return http.StatusLocked
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusForbidden
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanChangeStatus(t *testing.T) {
+	assert.True(t, canChangeStatus(userStatusActive, userStatusBanned))
+	assert.True(t, canChangeStatus(userStatusSuspended, userStatusSuspended))
+	assert.True(t, canChangeStatus(userStatusLocked, userStatusActive))
+	assert.False(t, canChangeStatus(userStatusActive, userStatusActive))
+	assert.False(t, canChangeStatus(userStatusBanned, userStatusDeleted))
+	assert.False(t, canChangeStatus(userStatusSuspended, userStatusLocked))
+	assert.False(t, canChangeStatus("unknown", userStatusActive))
+}
+
+func TestCurrentStatus(t *testing.T) {
+	past := pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+	future := pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true}
+
+	testCases := []struct {
+		name            string
+		status          string
+		expiresAt       pgtype.Timestamptz
+		emailVerifiedAt pgtype.Timestamptz
+		want            string
+	}{
+		{"Permanent", userStatusBanned, pgtype.Timestamptz{}, past, userStatusBanned},
+		{"NotExpired", userStatusSuspended, future, past, userStatusSuspended},
+		{"Expired", userStatusLocked, past, past, userStatusActive},
+		{"ExpiredUnverified", userStatusSuspended, past, pgtype.Timestamptz{}, userStatusPendingVerification},
+		{"NotRestricted", userStatusActive, past, past, userStatusActive},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, currentStatus(tc.status, tc.expiresAt, tc.emailVerifiedAt))
+		})
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	assert.NoError(t, statusError(userStatusActive))
+	assert.NoError(t, statusError(userStatusPendingVerification))
+
+	assert.ErrorIs(t, statusError(userStatusSuspended), ErrUserSuspended)
+	assert.Equal(t, http.StatusForbidden, statusErrorCode(statusError(userStatusBanned)))
+	assert.Equal(t, http.StatusLocked, statusErrorCode(statusError(userStatusLocked)))
+}
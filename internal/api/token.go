@@ -0,0 +1,136 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mauzec/user-api/internal/token"
+	"github.com/mauzec/user-api/internal/util"
+)
+
+type renewAccessTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type renewAccessTokenResponse struct {
+	Token          string    `json:"token"`
+	TokenExpiresAt time.Time `json:"token_expires_at"`
+}
+
+func (server *Server) renewAccessToken(ctx *gin.Context) {
+	var req renewAccessTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	refreshPayload, err := verifyToken(server.tokenMaker, req.RefreshToken, token.TypeRefresh)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	session, err := server.store.GetSession(ctx, refreshPayload.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ErrNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	// the session is the source of truth: a valid refresh token
+	// is not enough if its session was blocked or does not match
+	if session.IsBlocked {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("session is blocked")))
+		return
+	}
+	if session.Username != refreshPayload.Username {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("incorrect session user")))
+		return
+	}
+	if session.RefreshToken != req.RefreshToken {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("mismatched session token")))
+		return
+	}
+	if time.Now().After(session.ExpiresAt.Time) {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("session is expired")))
+		return
+	}
+	if err = server.userStates.check(ctx, refreshPayload); err != nil {
+		ctx.JSON(authErrorCode(err), errorResponse(err))
+		return
+	}
+
+	// the role could change since the login, so it is taken from the user
+	user, err := server.store.GetUserByUsername(ctx, refreshPayload.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ErrNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	if err = statusError(userCurrentStatus(user)); err != nil {
+		ctx.JSON(statusErrorCode(err), errorResponse(err))
+		return
+	}
+
+	// tokens issued before scopes were introduced get all scopes of the role
+	scopes := util.RoleScopes(user.Role)
+	if len(refreshPayload.Scopes) > 0 {
+		scopes = util.FilterScopes(user.Role, refreshPayload.Scopes)
+	}
+
+	accessToken, payload, err := server.tokenMaker.CreateToken(
+		token.TypeAccess,
+		user.Username,
+		user.Role,
+		scopes,
+		server.tokenParams.AccessTokenDuration,
+	)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, renewAccessTokenResponse{
+		Token:          accessToken,
+		TokenExpiresAt: payload.ExpiredAt,
+	})
+}
+
+type tokenPublicKeyResponse struct {
+	Version      string `json:"version"`
+	Purpose      string `json:"purpose"`
+	PublicKey    string `json:"public_key"`
+	PublicKeyPEM string `json:"public_key_pem"`
+}
+
+func (server *Server) getTokenPublicKey(ctx *gin.Context) {
+	maker, ok := server.tokenMaker.(token.PublicKeyMaker)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, errorResponse(ErrNotFound))
+		return
+	}
+
+	publicKey := maker.PublicKey()
+	publicKeyPEM, err := token.EncodeEd25519PublicKey(publicKey)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokenPublicKeyResponse{
+		Version:      "v2",
+		Purpose:      "public",
+		PublicKey:    base64.RawURLEncoding.EncodeToString(publicKey),
+		PublicKeyPEM: string(publicKeyPEM),
+	})
+}
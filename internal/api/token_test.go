@@ -0,0 +1,329 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/token"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRenewAccessTokenAPI(t *testing.T) {
+	user := randomUser()
+
+	testCases := []struct {
+		name          string
+		buildToken    func(t *testing.T, tokenMaker token.Maker) string
+		buildStubs    func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				return newRefreshToken(t, tokenMaker, user.Username, time.Hour)
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(newSession(t, tokenMaker, refreshToken), nil)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp renewAccessTokenResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.NotEmpty(t, resp.Token)
+				assert.WithinDuration(t, time.Now().Add(15*time.Minute), resp.TokenExpiresAt, time.Second)
+			},
+		},
+		{
+			name: "InvalidToken",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				return "invalid"
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "ExpiredToken",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				return newRefreshToken(t, tokenMaker, user.Username, -time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "AccessToken",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				accessToken, _, err := tokenMaker.CreateToken(token.TypeAccess, user.Username, util.RoleUser, util.RoleScopes(util.RoleUser), time.Hour)
+				assert.NoError(t, err)
+				return accessToken
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), token.ErrWrongTokenType.Error())
+			},
+		},
+		{
+			name: "SessionNotFound",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				return newRefreshToken(t, tokenMaker, user.Username, time.Hour)
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				return newRefreshToken(t, tokenMaker, user.Username, time.Hour)
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "BlockedSession",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				return newRefreshToken(t, tokenMaker, user.Username, time.Hour)
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				session := newSession(t, tokenMaker, refreshToken)
+				session.IsBlocked = true
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "IncorrectSessionUser",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				return newRefreshToken(t, tokenMaker, user.Username, time.Hour)
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				session := newSession(t, tokenMaker, refreshToken)
+				session.Username = "someone_else"
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "MismatchedSessionToken",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				return newRefreshToken(t, tokenMaker, user.Username, time.Hour)
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				session := newSession(t, tokenMaker, refreshToken)
+				session.RefreshToken = "another_token"
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "PasswordChanged",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				return newRefreshToken(t, tokenMaker, user.Username, time.Hour)
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(newSession(t, tokenMaker, refreshToken), nil)
+				store.EXPECT().
+					GetUserAuthState(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.GetUserAuthStateRow{
+						PasswordChangedAt: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+						Status:            userStatusActive,
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "SuspendedUser",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				return newRefreshToken(t, tokenMaker, user.Username, time.Hour)
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				suspended := user
+				suspended.Status = userStatusSuspended
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(newSession(t, tokenMaker, refreshToken), nil)
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(suspended, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "ExpiredSession",
+			buildToken: func(t *testing.T, tokenMaker token.Maker) string {
+				return newRefreshToken(t, tokenMaker, user.Username, time.Hour)
+			},
+			buildStubs: func(store *mockdb.MockStore, refreshToken string, tokenMaker token.Maker) {
+				session := newSession(t, tokenMaker, refreshToken)
+				session.ExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			server := newTestServer(t, store)
+			refreshToken := tc.buildToken(t, server.tokenMaker)
+			tc.buildStubs(store, refreshToken, server.tokenMaker)
+			stubAuthChecks(store)
+
+			body, err := json.Marshal(gin.H{"refresh_token": refreshToken})
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/tokens/renew_access", bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func newRefreshToken(t *testing.T, tokenMaker token.Maker, username string, duration time.Duration) string {
+	refreshToken, _, err := tokenMaker.CreateToken(token.TypeRefresh, username, util.RoleUser, util.RoleScopes(util.RoleUser), duration)
+	assert.NoError(t, err)
+	return refreshToken
+}
+
+// newSession builds the session row that login would have stored for refreshToken.
+func newSession(t *testing.T, tokenMaker token.Maker, refreshToken string) db.Session {
+	payload, err := tokenMaker.VerifyToken(refreshToken)
+	assert.NoError(t, err)
+
+	return db.Session{
+		ID:           payload.ID,
+		Username:     payload.Username,
+		RefreshToken: refreshToken,
+		ExpiresAt:    pgtype.Timestamptz{Time: payload.ExpiredAt, Valid: true},
+	}
+}
+
+func TestGetTokenPublicKeyAPI(t *testing.T) {
+	t.Run("PasetoP", func(t *testing.T) {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(t, err)
+		tokenMaker, err := token.NewPasetoPMaker(privateKey, nil)
+		assert.NoError(t, err)
+
+		server, err := NewServer(nil, tokenMaker, TokenParams{AccessTokenDuration: time.Minute * 15})
+		assert.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/.well-known/paseto-public-key", nil)
+		assert.NoError(t, err)
+
+		server.router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var resp tokenPublicKeyResponse
+		err = json.Unmarshal(recorder.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+
+		publicKey, err := base64.RawURLEncoding.DecodeString(resp.PublicKey)
+		assert.NoError(t, err)
+		assert.True(t, tokenMaker.PublicKey().Equal(ed25519.PublicKey(publicKey)))
+		assert.Contains(t, resp.PublicKeyPEM, "BEGIN PUBLIC KEY")
+	})
+
+	t.Run("SymmetricMaker", func(t *testing.T) {
+		server := newTestServer(t, nil)
+
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/.well-known/paseto-public-key", nil)
+		assert.NoError(t, err)
+
+		server.router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
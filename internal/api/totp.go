@@ -0,0 +1,324 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/util"
+)
+
+const (
+	// totpIssuer is the account issuer shown by authenticator apps
+	totpIssuer = "user-api"
+
+	recoveryCodeCount = 10
+	// recoveryCodeSize is the number of random bytes in a recovery code
+	recoveryCodeSize = 9
+
+	// mfaTokenSize is the number of random bytes in an mfa_pending token
+	mfaTokenSize = 32
+	// mfaMaxAttempts is how many codes can be tried with one mfa_pending token
+	mfaMaxAttempts = 5
+)
+
+var (
+	ErrTotpAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTotpNotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidMfaCode     = errors.New("invalid two-factor authentication code")
+	ErrInvalidMfaToken    = errors.New("mfa token is invalid or expired")
+	ErrTooManyMfaAttempts = errors.New("too many attempts, log in again")
+)
+
+type enrollTotpRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+type enrollTotpResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+// enrollTotp creates a new TOTP secret for the user. 2FA is enforced only
+// after the secret is confirmed with a code, see confirmTotp. Like a
+// password change it needs the current password, so a stolen token can
+// not turn on 2FA with a secret of its own and lock the user out.
+func (server *Server) enrollTotp(ctx *gin.Context) {
+	var req enrollTotpRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	user, ok := server.getOwnUser(ctx)
+	if !ok {
+		return
+	}
+
+	if err := server.passwordHasher.Check(user.HashedPassword, req.CurrentPassword); err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(ErrInvalidCurrentPassword))
+		return
+	}
+
+	secret, err := util.NewTOTPSecret()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	_, err = server.store.UpsertUserTotp(ctx, db.UpsertUserTotpParams{
+		UserID: user.ID,
+		Secret: secret,
+	})
+	if err != nil {
+		// a confirmed secret is never replaced
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusConflict, errorResponse(ErrTotpAlreadyEnabled))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollTotpResponse{
+		Secret:     secret,
+		OtpauthURI: util.TOTPURI(totpIssuer, user.Username, secret),
+	})
+}
+
+type confirmTotpRequest struct {
+	Code string `json:"code" binding:"required,numeric"`
+}
+
+type confirmTotpResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// confirmTotp turns on 2FA once the user sends a code from the enrolled
+// secret. The recovery codes are shown only in this response.
+func (server *Server) confirmTotp(ctx *gin.Context) {
+	var req confirmTotpRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	user, ok := server.getOwnUser(ctx)
+	if !ok {
+		return
+	}
+
+	totp, err := server.store.GetUserTotp(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrTotpNotEnrolled))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	if totp.ConfirmedAt.Valid {
+		ctx.JSON(http.StatusConflict, errorResponse(ErrTotpAlreadyEnabled))
+		return
+	}
+
+	step, ok := util.ValidateTOTP(totp.Secret, req.Code, time.Now())
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidMfaCode))
+		return
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = util.NewSecureToken(recoveryCodeSize)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+			return
+		}
+		hashes[i] = util.HashToken(codes[i])
+	}
+
+	_, err = server.store.ConfirmTotpTx(ctx, db.ConfirmTotpTxParams{
+		UserID:             user.ID,
+		Step:               step,
+		RecoveryCodeHashes: hashes,
+	})
+	if err != nil {
+		// confirmed by a concurrent request
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusConflict, errorResponse(ErrTotpAlreadyEnabled))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, confirmTotpResponse{RecoveryCodes: codes})
+}
+
+type mfaPendingResponse struct {
+	// always "mfa_pending", tells the response apart from loginResponse
+	Status            string    `json:"status"`
+	MfaToken          string    `json:"mfa_token"`
+	MfaTokenExpiresAt time.Time `json:"mfa_token_expires_at"`
+}
+
+// createMfaChallenge issues a short-lived mfa_pending token for a user
+// who has passed the password check.
+func (server *Server) createMfaChallenge(ctx *gin.Context, user db.User, scopes []string) (mfaPendingResponse, error) {
+	mfaToken, err := util.NewSecureToken(mfaTokenSize)
+	if err != nil {
+		return mfaPendingResponse{}, err
+	}
+
+	expiresAt := time.Now().Add(server.tokenParams.MfaTokenDuration)
+	_, err = server.store.CreateMfaChallenge(ctx, db.CreateMfaChallengeParams{
+		UserID:    user.ID,
+		TokenHash: util.HashToken(mfaToken),
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		Scopes:    scopes,
+	})
+	if err != nil {
+		return mfaPendingResponse{}, err
+	}
+
+	return mfaPendingResponse{
+		Status:            "mfa_pending",
+		MfaToken:          mfaToken,
+		MfaTokenExpiresAt: expiresAt,
+	}, nil
+}
+
+type loginMfaRequest struct {
+	MfaToken string `json:"mfa_token" binding:"required"`
+	// a TOTP code or a recovery code
+	Code string `json:"code" binding:"required"`
+}
+
+// loginMfa is the second login step for users with 2FA. It exchanges
+// the mfa_pending token and a valid code for the real tokens.
+func (server *Server) loginMfa(ctx *gin.Context) {
+	var req loginMfaRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+
+	challenge, err := server.store.GetMfaChallenge(ctx, util.HashToken(req.MfaToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(ErrInvalidMfaToken))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	_, err = server.store.IncrementMfaChallengeAttempts(ctx, db.IncrementMfaChallengeAttemptsParams{
+		ID:          challenge.ID,
+		MaxAttempts: mfaMaxAttempts,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusTooManyRequests, errorResponse(ErrTooManyMfaAttempts))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	user, err := server.store.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(ErrInvalidMfaToken))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	// the status could change since the password was checked
+	if err = statusError(userCurrentStatus(user)); err != nil {
+		ctx.JSON(statusErrorCode(err), errorResponse(err))
+		return
+	}
+
+	// codes are guessed like passwords, so they are throttled the same
+	clientIP := ctx.ClientIP()
+	wait, err := server.loginThrottle.wait(ctx, user.Username, clientIP)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	if wait > 0 {
+		setRetryAfter(ctx, wait)
+		ctx.JSON(http.StatusTooManyRequests, errorResponse(ErrTooManyLoginAttempts))
+		return
+	}
+
+	err = server.checkMfaCode(ctx, user, req.Code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidMfaCode) {
+			server.failLogin(ctx, user, err)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	server.loginThrottle.reset(ctx, usernameFailureKey(user.Username), ipFailureKey(clientIP))
+
+	_, err = server.store.UseMfaChallenge(ctx, challenge.ID)
+	if err != nil {
+		// used by a concurrent request
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(ErrInvalidMfaToken))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	// the role could change since the challenge was created
+	resp, err := server.createLoginSession(ctx, user, util.FilterScopes(user.Role, challenge.Scopes))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// checkMfaCode accepts a TOTP code that was not used before or an unused
+// recovery code, using it up. Otherwise ErrInvalidMfaCode is returned.
+func (server *Server) checkMfaCode(ctx *gin.Context, user db.User, code string) error {
+	totp, err := server.store.GetUserTotp(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidMfaCode
+		}
+		return err
+	}
+	if !totp.ConfirmedAt.Valid {
+		return ErrInvalidMfaCode
+	}
+
+	if step, ok := util.ValidateTOTP(totp.Secret, code, time.Now()); ok {
+		_, err = server.store.UseUserTotpStep(ctx, db.UseUserTotpStepParams{
+			UserID:       user.ID,
+			LastUsedStep: step,
+		})
+	} else {
+		_, err = server.store.UseTotpRecoveryCode(ctx, db.UseTotpRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: util.HashToken(strings.TrimSpace(code)),
+		})
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidMfaCode
+	}
+	return err
+}
@@ -0,0 +1,585 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func randomUserTotp(t *testing.T, user db.User, confirmed bool) db.UserTotp {
+	secret, err := util.NewTOTPSecret()
+	assert.NoError(t, err)
+
+	return db.UserTotp{
+		UserID:      user.ID,
+		Secret:      secret,
+		ConfirmedAt: pgtype.Timestamptz{Time: time.Now(), Valid: confirmed},
+	}
+}
+
+func currentTOTPCode(t *testing.T, secret string) string {
+	code, err := util.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	return code
+}
+
+func TestEnrollTotpAPI(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"current_password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpsertUserTotp(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.UpsertUserTotpParams) (db.UserTotp, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						return db.UserTotp{UserID: arg.UserID, Secret: arg.Secret}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp enrollTotpResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.NotEmpty(t, resp.Secret)
+				assert.Contains(t, resp.OtpauthURI, "otpauth://totp/")
+				assert.Contains(t, resp.OtpauthURI, "secret="+resp.Secret)
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			body: gin.H{"current_password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpsertUserTotp(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "WrongPassword",
+			body: gin.H{"current_password": "wrong" + password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpsertUserTotp(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), ErrInvalidCurrentPassword.Error())
+			},
+		},
+		{
+			name: "NoPassword",
+			body: gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpsertUserTotp(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthChecks(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			url := fmt.Sprintf("/users/%s/totp", user.Username)
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			addAuthHeader(t, req, server.tokenMaker, authTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestConfirmTotpAPI(t *testing.T) {
+	user := randomUser()
+	totp := randomUserTotp(t, user, false)
+	confirmed := randomUserTotp(t, user, true)
+
+	testCases := []struct {
+		name          string
+		code          func(t *testing.T) string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			code: func(t *testing.T) string { return currentTOTPCode(t, totp.Secret) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(totp, nil)
+				store.EXPECT().
+					ConfirmTotpTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.ConfirmTotpTxParams) (db.ConfirmTotpTxResult, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						assert.InDelta(t, util.TOTPStep(time.Now()), arg.Step, 1)
+						assert.Len(t, arg.RecoveryCodeHashes, recoveryCodeCount)
+						return db.ConfirmTotpTxResult{UserTotp: confirmed}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp confirmTotpResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Len(t, resp.RecoveryCodes, recoveryCodeCount)
+			},
+		},
+		{
+			name: "WrongCode",
+			code: func(t *testing.T) string {
+				code, err := util.TOTPCode(totp.Secret, time.Now().Add(-time.Hour))
+				assert.NoError(t, err)
+				return code
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(totp, nil)
+				store.EXPECT().
+					ConfirmTotpTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NotEnrolled",
+			code: func(t *testing.T) string { return "123456" },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "AlreadyConfirmed",
+			code: func(t *testing.T) string { return currentTOTPCode(t, confirmed.Secret) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(confirmed, nil)
+				store.EXPECT().
+					ConfirmTotpTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthChecks(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{"code": tc.code(t)})
+			assert.NoError(t, err)
+
+			url := fmt.Sprintf("/users/%s/totp/confirm", user.Username)
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			addAuthHeader(t, req, server.tokenMaker, authTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginMfaAPI(t *testing.T) {
+	user := randomUser()
+	totp := randomUserTotp(t, user, true)
+
+	mfaToken := util.RandomString(43)
+	challenge := db.MfaChallenge{
+		ID:        util.RandomInt(1, 1000),
+		UserID:    user.ID,
+		TokenHash: util.HashToken(mfaToken),
+	}
+	recoveryCode := util.RandomString(12)
+
+	// stubChallenge lets the mfa_pending token pass
+	stubChallenge := func(store *mockdb.MockStore) {
+		store.EXPECT().
+			GetMfaChallenge(gomock.Any(), gomock.Eq(challenge.TokenHash)).
+			Times(1).
+			Return(challenge, nil)
+		store.EXPECT().
+			IncrementMfaChallengeAttempts(gomock.Any(), gomock.Eq(db.IncrementMfaChallengeAttemptsParams{
+				ID:          challenge.ID,
+				MaxAttempts: mfaMaxAttempts,
+			})).
+			Times(1).
+			Return(challenge, nil)
+		store.EXPECT().
+			GetUserByID(gomock.Any(), gomock.Eq(user.ID)).
+			Times(1).
+			Return(user, nil)
+		store.EXPECT().
+			GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+			Times(1).
+			Return(totp, nil)
+	}
+
+	testCases := []struct {
+		name          string
+		body          func(t *testing.T) gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": currentTOTPCode(t, totp.Secret)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				stubChallenge(store)
+				store.EXPECT().
+					UseUserTotpStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.UseUserTotpStepParams) (db.UserTotp, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						assert.InDelta(t, util.TOTPStep(time.Now()), arg.LastUsedStep, 1)
+						return totp, nil
+					})
+				store.EXPECT().
+					ResetLoginFailures(gomock.Any(), gomock.Eq([]string{
+						usernameFailureKey(user.Username),
+						ipFailureKey(""),
+					})).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					UseMfaChallenge(gomock.Any(), gomock.Eq(challenge.ID)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp loginResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.NotEmpty(t, resp.Token)
+				assert.NotEmpty(t, resp.RefreshToken)
+			},
+		},
+		{
+			name: "RecoveryCode",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": recoveryCode}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				stubChallenge(store)
+				store.EXPECT().
+					UseTotpRecoveryCode(gomock.Any(), gomock.Eq(db.UseTotpRecoveryCodeParams{
+						UserID:   user.ID,
+						CodeHash: util.HashToken(recoveryCode),
+					})).
+					Times(1).
+					Return(db.TotpRecoveryCode{}, nil)
+				store.EXPECT().
+					UseMfaChallenge(gomock.Any(), gomock.Eq(challenge.ID)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "ReplayedCode",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": currentTOTPCode(t, totp.Secret)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				stubChallenge(store)
+				store.EXPECT().
+					UseUserTotpStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(db.LoginFailure{Failures: 1}, nil)
+				store.EXPECT().
+					UseMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "WrongCode",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": "not-a-code"}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				stubChallenge(store)
+				store.EXPECT().
+					UseTotpRecoveryCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TotpRecoveryCode{}, sql.ErrNoRows)
+				// counted like a wrong password
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(_ context.Context, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+						assert.Contains(t, []string{usernameFailureKey(user.Username), ipFailureKey("")}, arg.Key)
+						return db.LoginFailure{Key: arg.Key, Failures: 1}, nil
+					})
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "WrongCodeLockout",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": "not-a-code"}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				stubChallenge(store)
+				store.EXPECT().
+					UseTotpRecoveryCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TotpRecoveryCode{}, sql.ErrNoRows)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(db.LoginFailure{Failures: int32(DefaultLoginThrottleParams.LockoutThreshold)}, nil)
+				store.EXPECT().
+					UpdateUserStatus(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Throttled",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": currentTOTPCode(t, totp.Secret)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					IncrementMfaChallengeAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUserByID(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Eq(usernameFailureKey(user.Username))).
+					Times(1).
+					Return(db.LoginFailure{
+						Key:          usernameFailureKey(user.Username),
+						Failures:     5,
+						LastFailedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+					}, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				assert.NotEmpty(t, recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name: "TooManyAttempts",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": currentTOTPCode(t, totp.Secret)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					IncrementMfaChallengeAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.MfaChallenge{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserByID(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidMfaToken",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": "wrong", "code": currentTOTPCode(t, totp.Secret)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.MfaChallenge{}, sql.ErrNoRows)
+				store.EXPECT().
+					IncrementMfaChallengeAttempts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidRequest",
+			body: func(t *testing.T) gin.H {
+				return gin.H{"mfa_token": mfaToken}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubLoginThrottle(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body(t))
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login/mfa", bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
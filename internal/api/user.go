@@ -1,18 +1,30 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	db "github.com/mauzec/user-api/db/sqlc"
 	"github.com/mauzec/user-api/internal/token"
 	"github.com/mauzec/user-api/internal/util"
 )
 
+var (
+	ErrInvalidScope       = errors.New("requested scopes are not allowed")
+	ErrEmailInUse         = errors.New("email is already in use")
+	ErrUsernameTaken      = errors.New("username is already taken")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+)
+
 type createUserRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=32,alphanum"`
 	Fullname string `json:"fullname" binding:"required,min=3,max=64"`
@@ -20,34 +32,53 @@ type createUserRequest struct {
 	Age      int32  `json:"age" binding:"required,min=18,max=60"`
 	Email    string `json:"email" binding:"required,email"`
 	Phone    string `json:"phone" binding:"required,phone"`
-	Password string `json:"password" binding:"required,min=5,max=64"`
+	Password string `json:"password" binding:"required"`
+}
+
+type signupResponse struct {
+	Message string `json:"message"`
+}
+
+// privateSignupResponse answers every signup in private mode, whether it
+// created the user or not.
+var privateSignupResponse = signupResponse{
+	Message: "check your email to finish signing up",
 }
 
 type userResponse struct {
-	ID        int64     `json:"id"`
-	Username  string    `json:"username"`
-	FullName  string    `json:"fullname"`
-	Gender    string    `json:"gender"`
-	Age       int32     `json:"age"`
-	Avatar    string    `json:"avatar"`
-	Status    string    `json:"status"`
-	Email     string    `json:"email"`
-	Phone     string    `json:"phone"`
-	CreatedAt time.Time `json:"created_at"`
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// the username as the user typed it at signup, usernames are matched ignoring case
+	DisplayUsername string    `json:"display_username"`
+	FullName        string    `json:"fullname"`
+	Gender          string    `json:"gender"`
+	Age             int32     `json:"age"`
+	Avatar          string    `json:"avatar"`
+	Status          string    `json:"status"`
+	Role            string    `json:"role"`
+	Email           string    `json:"email"`
+	EmailVerified   bool      `json:"email_verified"`
+	Phone           string    `json:"phone"`
+	PhoneVerified   bool      `json:"phone_verified"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 func newUserResponse(user db.User) userResponse {
 	return userResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		FullName:  user.FullName,
-		Gender:    user.Gender,
-		Age:       user.Age,
-		Avatar:    user.Avatar,
-		Status:    user.Status,
-		Email:     user.Email,
-		Phone:     user.Phone,
-		CreatedAt: user.CreatedAt.Time,
+		ID:              user.ID,
+		Username:        user.Username,
+		DisplayUsername: user.DisplayUsername,
+		FullName:        user.FullName,
+		Gender:          user.Gender,
+		Age:             user.Age,
+		Avatar:          user.Avatar,
+		Status:          userCurrentStatus(user),
+		Role:            user.Role,
+		Email:           user.Email,
+		EmailVerified:   user.EmailVerifiedAt.Valid,
+		Phone:           user.Phone,
+		PhoneVerified:   user.PhoneVerifiedAt.Valid,
+		CreatedAt:       user.CreatedAt.Time,
 	}
 }
 
@@ -57,27 +88,52 @@ func (server *Server) createUser(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid request body")))
 		return
 	}
+	if !server.checkNewPassword(ctx, "password", req.Password, util.PasswordOwner{
+		Username: req.Username,
+		Email:    req.Email,
+	}) {
+		return
+	}
+
+	hashedPassword, err := server.passwordHasher.Hash(req.Password)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("something went wrong")))
+		return
+	}
 
-	hashedPassword, err := util.HashPassword(req.Password)
+	code, err := util.NewSecureToken(verificationCodeSize)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("something went wrong")))
 		return
 	}
+	expiresAt := time.Now().Add(server.tokenParams.EmailVerificationDuration)
 
-	user, err := server.store.CreateUser(ctx, db.CreateUserParams{
-		Username:       req.Username,
-		FullName:       req.Fullname,
-		Gender:         req.Gender,
-		Age:            req.Age,
-		Email:          req.Email,
-		Phone:          req.Phone,
-		HashedPassword: hashedPassword,
+	// new users are pending verification until they send back the emailed code
+	result, err := server.store.CreateUserTx(ctx, db.CreateUserTxParams{
+		CreateUserParams: db.CreateUserParams{
+			Username:        normalizeUsername(req.Username),
+			DisplayUsername: req.Username,
+			FullName:        req.Fullname,
+			Gender:          req.Gender,
+			Age:             req.Age,
+			Email:           req.Email,
+			Phone:           req.Phone,
+			HashedPassword:  hashedPassword,
+		},
+		VerificationCodeHash:      util.HashToken(code),
+		VerificationCodeExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
 	})
 	if err != nil {
-		if pgErr, ok := err.(*pgconn.PgError); ok {
-			switch pgErr.Code {
-			case "23505":
-				ctx.JSON(http.StatusForbidden, errorResponse(errors.New("this user already exists")))
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == "23505" {
+				err = uniqueViolationError(pgErr)
+				if server.privateSignup {
+					server.sendSignupConflict(ctx, req, err)
+					ctx.JSON(http.StatusAccepted, privateSignupResponse)
+					return
+				}
+				ctx.JSON(http.StatusConflict, errorResponse(err))
 				return
 			}
 		}
@@ -85,18 +141,35 @@ func (server *Server) createUser(ctx *gin.Context) {
 		return
 	}
 
-	resp := newUserResponse(user)
+	// the user is committed, a lost code can be sent again
+	err = server.sendVerificationCode(ctx, result.User, code, expiresAt)
+	if err != nil {
+		log.Println("unable to send verification code:", err)
+	}
+
+	if server.privateSignup {
+		ctx.JSON(http.StatusAccepted, privateSignupResponse)
+		return
+	}
+	resp := newUserResponse(result.User)
 	ctx.JSON(http.StatusOK, resp)
 }
 
 type loginRequest struct {
-	Username string `json:"username" binding:"required,alphanum"`
-	Password string `json:"password" binding:"required,min=6"`
+	// a username, an email or an E.164 phone number
+	Identifier string `json:"identifier" binding:"required,max=254,identifier"`
+	Password   string `json:"password" binding:"required"`
+	// reduced scopes for the tokens, all scopes of the role by default
+	Scopes []string `json:"scopes"`
 }
 
 type loginResponse struct {
-	Token string       `json:"token"`
-	User  userResponse `json:"user"`
+	SessionID             uuid.UUID    `json:"session_id"`
+	Token                 string       `json:"token"`
+	TokenExpiresAt        time.Time    `json:"token_expires_at"`
+	RefreshToken          string       `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+	User                  userResponse `json:"user"`
 }
 
 func (server *Server) loginUser(ctx *gin.Context) {
@@ -106,37 +179,197 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		return
 	}
 
-	user, err := server.store.GetUserByUsername(ctx, req.Username)
+	user, err := server.getUserByIdentifier(ctx, req.Identifier)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	found := err == nil
+
+	// failures are counted per user, whichever identifier was used
+	failureName := strings.ToLower(req.Identifier)
+	if found {
+		failureName = user.Username
+	}
+	clientIP := ctx.ClientIP()
+	wait, err := server.loginThrottle.wait(ctx, failureName, clientIP)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(ErrNotFound))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+	if wait > 0 {
+		setRetryAfter(ctx, wait)
+		ctx.JSON(http.StatusTooManyRequests, errorResponse(ErrTooManyLoginAttempts))
+		return
+	}
+
+	// unknown and locked users get the answer of a wrong password after as
+	// long a check, so no answer tells whether the user exists; locked users
+	// are told about the lock by email, see failLogin
+	status := userCurrentStatus(user)
+	if !found || status == userStatusLocked {
+		server.checkDummyPassword(req.Password)
+		// guessing counts too
+		if _, err = server.loginThrottle.fail(ctx, failureName, clientIP); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+			return
+		}
+		ctx.JSON(http.StatusUnauthorized, errorResponse(ErrInvalidCredentials))
+		return
+	}
+
+	err = server.passwordHasher.Check(user.HashedPassword, req.Password)
+	if err != nil {
+		server.failLogin(ctx, user, ErrInvalidCredentials)
+		return
+	}
+	server.rehashPassword(user, req.Password)
+
+	if status == userStatusPendingVerification && !server.allowUnverifiedLogin {
+		ctx.JSON(http.StatusForbidden, errorResponse(ErrEmailNotVerified))
+		return
+	}
+	if err = statusError(status); err != nil {
+		ctx.JSON(statusErrorCode(err), errorResponse(err))
+		return
+	}
+
+	scopes := util.RoleScopes(user.Role)
+	if len(req.Scopes) > 0 {
+		if !util.AllowedScopes(user.Role, req.Scopes) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidScope))
 			return
 		}
+		scopes = util.FilterScopes(user.Role, req.Scopes)
+	}
 
+	totp, err := server.store.GetUserTotp(ctx, user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
 		return
 	}
+	if err == nil && totp.ConfirmedAt.Valid {
+		// the password is right, but the code is still needed, see loginMfa
+		resp, err := server.createMfaChallenge(ctx, user, scopes)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+			return
+		}
+		ctx.JSON(http.StatusOK, resp)
+		return
+	}
 
-	err = util.CheckPassword(user.HashedPassword, req.Password)
+	// with 2FA the counters are reset by loginMfa, so wrong codes add up
+	// across challenges
+	server.loginThrottle.reset(ctx, usernameFailureKey(user.Username), ipFailureKey(clientIP))
+	resp, err := server.createLoginSession(ctx, user, scopes)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("invalid username or password")))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
 		return
 	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+const (
+	identifierUsername = "username"
+	identifierEmail    = "email"
+	identifierPhone    = "phone"
+)
+
+// identifierKind tells what a login identifier is. Usernames can hold
+// neither "@" nor "+", so there is no ambiguity.
+func identifierKind(identifier string) string {
+	switch {
+	case strings.Contains(identifier, "@"):
+		return identifierEmail
+	case strings.HasPrefix(identifier, "+"):
+		return identifierPhone
+	}
+	return identifierUsername
+}
+
+// getUserByIdentifier finds the user by a username, an email or a phone.
+// Emails and phones are matched ignoring case. Phones are not unique, so
+// a phone shared by several users finds none of them.
+func (server *Server) getUserByIdentifier(ctx context.Context, identifier string) (db.User, error) {
+	switch identifierKind(identifier) {
+	case identifierEmail:
+		return server.store.GetUserByEmail(ctx, identifier)
+	case identifierPhone:
+		users, err := server.store.ListUsersByPhone(ctx, strings.ReplaceAll(identifier, " ", ""))
+		if err != nil {
+			return db.User{}, err
+		}
+		if len(users) != 1 {
+			return db.User{}, sql.ErrNoRows
+		}
+		return users[0], nil
+	}
+	return server.store.GetUserByUsername(ctx, normalizeUsername(identifier))
+}
+
+// normalizeUsername returns the form usernames are stored and compared in.
+func normalizeUsername(username string) string {
+	return strings.ToLower(username)
+}
+
+// uniqueViolationError tells which unique field of the user is taken.
+func uniqueViolationError(pgErr *pgconn.PgError) error {
+	switch pgErr.ConstraintName {
+	case "users_username_unique":
+		return ErrUsernameTaken
+	case "users_email_idx":
+		return ErrEmailInUse
+	}
+	return errors.New("this user already exists")
+}
 
-	token, err := server.tokenMaker.CreateToken(
-		req.Username,
+// createLoginSession issues an access token and a refresh token with its session.
+// Both tokens carry the scopes, so renewed access tokens keep them.
+func (server *Server) createLoginSession(ctx *gin.Context, user db.User, scopes []string) (loginResponse, error) {
+	accessToken, payload, err := server.tokenMaker.CreateToken(
+		token.TypeAccess,
+		user.Username,
+		user.Role,
+		scopes,
 		server.tokenParams.AccessTokenDuration,
 	)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
-		return
+		return loginResponse{}, err
 	}
 
-	resp := loginResponse{
-		Token: token,
-		User:  newUserResponse(user),
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(
+		token.TypeRefresh,
+		user.Username,
+		user.Role,
+		scopes,
+		server.tokenParams.RefreshTokenDuration,
+	)
+	if err != nil {
+		return loginResponse{}, err
 	}
-	ctx.JSON(http.StatusOK, resp)
+
+	session, err := server.store.CreateSession(ctx, db.CreateSessionParams{
+		ID:           refreshPayload.ID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    ctx.Request.UserAgent(),
+		ClientIp:     ctx.ClientIP(),
+		IsBlocked:    false,
+		ExpiresAt:    pgtype.Timestamptz{Time: refreshPayload.ExpiredAt, Valid: true},
+	})
+	if err != nil {
+		return loginResponse{}, err
+	}
+
+	return loginResponse{
+		SessionID:             session.ID,
+		Token:                 accessToken,
+		TokenExpiresAt:        payload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+		User:                  newUserResponse(user),
+	}, nil
 }
 
 type getUserUri struct {
@@ -180,6 +413,37 @@ func (server *Server) getUserByUsername(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, newUserResponse(user))
 }
 
+// getOwnUser loads the user of the uri, which must be the user of the auth
+// token. On failure the response is written and false is returned.
+func (server *Server) getOwnUser(ctx *gin.Context) (db.User, bool) {
+	var uri getUserUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return db.User{}, false
+	}
+
+	payload, err := getAuthPayload(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return db.User{}, false
+	}
+	if payload.Username != uri.Username {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(ErrPermissionDenied))
+		return db.User{}, false
+	}
+
+	user, err := server.store.GetUserByUsername(ctx, uri.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(ErrNotFound))
+			return db.User{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return db.User{}, false
+	}
+	return user, true
+}
+
 type updateUserRequest struct {
 	Fullname *string `json:"fullname" binding:"omitempty,min=3,max=64"`
 	Email    *string `json:"email" binding:"omitempty,email"`
@@ -205,7 +469,7 @@ func (server *Server) updateUser(ctx *gin.Context) {
 		return
 	}
 
-	// user can update only his own data!!
+	// users can update only their own data, admins can update anyone's
 	payloadVal, exists := ctx.Get(authPayloadKey)
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, errorResponse(ErrMissingAuthPayload))
@@ -216,7 +480,8 @@ func (server *Server) updateUser(ctx *gin.Context) {
 		ctx.JSON(http.StatusUnauthorized, errorResponse(ErrMissingAuthPayload))
 		return
 	}
-	if payload.Username != uri.Username {
+	isAdmin := payload.Role == util.RoleAdmin && payload.HasScope(util.ScopeUsersAdmin)
+	if payload.Username != uri.Username && !isAdmin {
 		ctx.JSON(http.StatusUnauthorized, errorResponse(ErrPermissionDenied))
 		return
 	}
@@ -253,8 +518,20 @@ func (server *Server) updateUser(ctx *gin.Context) {
 
 	updated, err := server.store.UpdateUser(ctx, args)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			ctx.JSON(http.StatusConflict, errorResponse(uniqueViolationError(pgErr)))
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
 		return
 	}
+
+	// a new email is unverified until it gets its own code back
+	if !strings.EqualFold(user.Email, updated.Email) {
+		if err = server.startEmailVerification(ctx, updated); err != nil {
+			log.Println("unable to start email verification:", err)
+		}
+	}
 	ctx.JSON(http.StatusOK, newUserResponse(updated))
 }
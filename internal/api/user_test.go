@@ -2,15 +2,21 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	mockdb "github.com/mauzec/user-api/db/mock"
 	db "github.com/mauzec/user-api/db/sqlc"
 	"github.com/mauzec/user-api/internal/token"
@@ -20,17 +26,31 @@ import (
 )
 
 func randomUser() db.User {
+	username := util.RandomString(8)
 	return db.User{
-		ID:       util.RandomInt(1, 10000),
-		Username: util.RandomString(8),
-		FullName: util.RandomString(10),
-		Gender:   "M",
-		Age:      int32(util.RandomInt(18, 60)),
-		Phone:    util.RandomPhone(),
-		Email:    util.RandomEmail(),
+		ID:              util.RandomInt(1, 10000),
+		Username:        username,
+		DisplayUsername: username,
+		FullName:        util.RandomString(10),
+		Gender:          "M",
+		Age:             int32(util.RandomInt(18, 60)),
+		Phone:           util.RandomPhone(),
+		Email:           util.RandomEmail(),
+		Status:          userStatusActive,
+		Role:            util.RoleUser,
 	}
 }
 
+func randomUserWithPassword(t *testing.T) (db.User, string) {
+	password := util.RandomString(8)
+	hashedPassword, err := util.HashPassword(password)
+	assert.NoError(t, err)
+
+	user := randomUser()
+	user.HashedPassword = hashedPassword
+	return user, password
+}
+
 func assertBodyMatchUser(t *testing.T, body *bytes.Buffer, user db.User) {
 	data, err := io.ReadAll(body)
 	assert.NoError(t, err)
@@ -51,6 +71,200 @@ func assertBodyNoUser(t *testing.T, body *bytes.Buffer) {
 	assert.Zero(t, user)
 }
 
+func TestCreateUserAPI(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+	user.Status = userStatusPendingVerification
+
+	body := gin.H{
+		"username": user.Username,
+		"fullname": user.FullName,
+		"gender":   user.Gender,
+		"age":      user.Age,
+		"email":    user.Email,
+		"phone":    user.Phone,
+		"password": password,
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		notifyErr     error
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier)
+	}{
+		{
+			name: "OK",
+			body: body,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+						assert.Equal(t, user.Username, arg.Username)
+						assert.Equal(t, user.Email, arg.Email)
+						assert.NoError(t, util.CheckPassword(arg.HashedPassword, password))
+						assert.Len(t, arg.VerificationCodeHash, 64)
+						assert.WithinDuration(t, time.Now().Add(24*time.Hour), arg.VerificationCodeExpiresAt.Time, time.Second)
+						return db.CreateUserTxResult{User: user}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assertBodyMatchUser(t, recorder.Body, user)
+				assert.Len(t, notifier.messages, 1)
+				assert.Equal(t, user.Email, notifier.messages[0].To)
+			},
+		},
+		{
+			name:      "NotifierError",
+			body:      body,
+			notifyErr: errors.New("smtp is down"),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateUserTxResult{User: user}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				// the user is created anyway, the code can be sent again
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assertBodyMatchUser(t, recorder.Body, user)
+				assert.Len(t, notifier.messages, 1)
+			},
+		},
+		{
+			name: "MixedCaseUsername",
+			body: gin.H{
+				"username": "Mixed" + user.Username,
+				"fullname": user.FullName,
+				"gender":   user.Gender,
+				"age":      user.Age,
+				"email":    user.Email,
+				"phone":    user.Phone,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+						assert.Equal(t, "mixed"+user.Username, arg.Username)
+						assert.Equal(t, "Mixed"+user.Username, arg.DisplayUsername)
+
+						created := user
+						created.Username = arg.Username
+						created.DisplayUsername = arg.DisplayUsername
+						return db.CreateUserTxResult{User: created}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp userResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Equal(t, "mixed"+user.Username, resp.Username)
+				assert.Equal(t, "Mixed"+user.Username, resp.DisplayUsername)
+			},
+		},
+		{
+			name: "UsernameTaken",
+			body: body,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateUserTxResult{}, &pgconn.PgError{Code: "23505", ConstraintName: "users_username_unique"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), ErrUsernameTaken.Error())
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name: "EmailInUse",
+			body: body,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateUserTxResult{}, &pgconn.PgError{Code: "23505", ConstraintName: "users_email_idx"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), ErrEmailInUse.Error())
+			},
+		},
+		{
+			name: "PasswordPolicy",
+			body: gin.H{
+				"username": user.Username,
+				"fullname": user.FullName,
+				"gender":   user.Gender,
+				"age":      user.Age,
+				"email":    user.Email,
+				"phone":    user.Phone,
+				"password": strings.ToUpper(user.Username),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertPasswordFieldErrors(t, recorder, "password", util.ErrPasswordContainsUsername)
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name: "InvalidEmail",
+			body: gin.H{
+				"username": user.Username,
+				"fullname": user.FullName,
+				"gender":   user.Gender,
+				"age":      user.Age,
+				"email":    "invalid-email",
+				"phone":    user.Phone,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			notifier := &testNotifier{err: tc.notifyErr}
+			server := newTestServer(t, store, WithNotifier(notifier))
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder, notifier)
+		})
+	}
+}
+
 func TestGetUserAPI(t *testing.T) {
 	user1 := randomUser()
 
@@ -82,6 +296,27 @@ func TestGetUserAPI(t *testing.T) {
 				assertBodyMatchUser(t, recorder.Body, user1)
 			},
 		},
+		{
+			name:     "MissingScope",
+			username: user1.Username,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addScopedAuthHeader(t, req, tokenMaker,
+					authTypeBearer,
+					user1.Username,
+					util.RoleUser,
+					[]string{util.ScopeUsersWrite},
+					time.Minute*15,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
 		{
 			name:     "NotFound",
 			username: user1.Username,
@@ -146,6 +381,7 @@ func TestGetUserAPI(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			store := mockdb.NewMockStore(ctrl)
+			stubAuthChecks(store)
 			tc.buildStubs(store)
 
 			server := newTestServer(t, store)
@@ -163,7 +399,858 @@ func TestGetUserAPI(t *testing.T) {
 
 }
 
-// not implemented; like testgetuserapi
-func TestUpdateUserAPI(t *testing.T) {
+func TestLoginUserAPI(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"identifier": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateSessionParams) (db.Session, error) {
+						return db.Session{
+							ID:           arg.ID,
+							Username:     arg.Username,
+							RefreshToken: arg.RefreshToken,
+							ExpiresAt:    arg.ExpiresAt,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp loginResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.NotEmpty(t, resp.Token)
+				assert.NotEmpty(t, resp.RefreshToken)
+				assert.NotZero(t, resp.SessionID)
+				assert.True(t, resp.RefreshTokenExpiresAt.After(resp.TokenExpiresAt))
+				assert.Equal(t, newUserResponse(user), resp.User)
+			},
+		},
+		{
+			name: "MfaPending",
+			body: gin.H{"identifier": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{
+						UserID:      user.ID,
+						ConfirmedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+					}, nil)
+				store.EXPECT().
+					CreateMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateMfaChallengeParams) (db.MfaChallenge, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						assert.WithinDuration(t, time.Now().Add(5*time.Minute), arg.ExpiresAt.Time, time.Second)
+						return db.MfaChallenge{UserID: arg.UserID, TokenHash: arg.TokenHash}, nil
+					})
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp mfaPendingResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Equal(t, "mfa_pending", resp.Status)
+				assert.NotEmpty(t, resp.MfaToken)
+				assert.NotContains(t, recorder.Body.String(), `"token"`)
+			},
+		},
+		{
+			name: "UserNotFound",
+			body: gin.H{"identifier": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), ErrInvalidCredentials.Error())
+			},
+		},
+		{
+			name: "WrongPassword",
+			body: gin.H{"identifier": user.Username, "password": "wrong_password"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "CreateSessionError",
+			body: gin.H{"identifier": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "SuspendedUser",
+			body: gin.H{"identifier": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				suspended := user
+				suspended.Status = userStatusSuspended
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(suspended, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "LockedUser",
+			body: gin.H{"identifier": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				locked := user
+				locked.Status = userStatusLocked
+				locked.StatusExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(locked, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "BanExpired",
+			body: gin.H{"identifier": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				banned := user
+				banned.Status = userStatusBanned
+				banned.StatusExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+				banned.EmailVerifiedAt = pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true}
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(banned, nil)
+				store.EXPECT().
+					GetUserTotp(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp loginResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Equal(t, userStatusActive, resp.User.Status)
+			},
+		},
+		{
+			name: "InvalidRequest",
+			body: gin.H{"identifier": "not valid!", "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			stubLoginThrottle(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginUnverifiedUser(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+	user.Status = userStatusPendingVerification
+
+	testCases := []struct {
+		name   string
+		opts   []Option
+		status int
+	}{
+		{
+			name:   "Blocked",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "Allowed",
+			opts:   []Option{WithUnverifiedLogin(true)},
+			status: http.StatusOK,
+		},
+	}
 
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			stubLoginThrottle(store)
+			store.EXPECT().
+				GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+				Times(1).
+				Return(user, nil)
+			store.EXPECT().
+				GetUserTotp(gomock.Any(), gomock.Any()).
+				AnyTimes().
+				Return(db.UserTotp{}, sql.ErrNoRows)
+			store.EXPECT().
+				CreateSession(gomock.Any(), gomock.Any()).
+				AnyTimes().
+				Return(db.Session{}, nil)
+
+			server := newTestServer(t, store, tc.opts...)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{"identifier": user.Username, "password": password})
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.status, recorder.Code)
+		})
+	}
+}
+
+func TestLoginScopes(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+
+	testCases := []struct {
+		name   string
+		scopes []string
+		status int
+		want   []string
+	}{
+		{
+			name:   "Default",
+			status: http.StatusOK,
+			want:   util.RoleScopes(util.RoleUser),
+		},
+		{
+			name:   "Reduced",
+			scopes: []string{util.ScopeUsersRead},
+			status: http.StatusOK,
+			want:   []string{util.ScopeUsersRead},
+		},
+		{
+			name:   "NotAllowed",
+			scopes: []string{util.ScopeUsersRead, util.ScopeUsersAdmin},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			stubLoginThrottle(store)
+			store.EXPECT().
+				GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+				Times(1).
+				Return(user, nil)
+			store.EXPECT().
+				GetUserTotp(gomock.Any(), gomock.Any()).
+				AnyTimes().
+				Return(db.UserTotp{}, sql.ErrNoRows)
+			store.EXPECT().
+				CreateSession(gomock.Any(), gomock.Any()).
+				AnyTimes().
+				Return(db.Session{}, nil)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{
+				"identifier": user.Username,
+				"password":   password,
+				"scopes":     tc.scopes,
+			})
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.status, recorder.Code)
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var resp loginResponse
+			err = json.Unmarshal(recorder.Body.Bytes(), &resp)
+			assert.NoError(t, err)
+
+			for _, tok := range []string{resp.Token, resp.RefreshToken} {
+				payload, err := server.tokenMaker.VerifyToken(tok)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, payload.Scopes)
+			}
+		})
+	}
+}
+
+// not implemented; like testgetuserapi
+func TestLoginIdentifier(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+	user.Email = "John.Doe@example.com"
+	user.Phone = "+15550100"
+
+	testCases := []struct {
+		name       string
+		identifier string
+		buildStubs func(store *mockdb.MockStore)
+		status     int
+	}{
+		{
+			name:       "Username",
+			identifier: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:       "Email",
+			identifier: "john.doe@EXAMPLE.com",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq("john.doe@EXAMPLE.com")).
+					Times(1).
+					Return(user, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:       "Phone",
+			identifier: "+1 555 0100",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsersByPhone(gomock.Any(), gomock.Eq(user.Phone)).
+					Times(1).
+					Return([]db.User{user}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:       "SharedPhone",
+			identifier: user.Phone,
+			buildStubs: func(store *mockdb.MockStore) {
+				other := randomUser()
+				other.Phone = user.Phone
+				store.EXPECT().
+					ListUsersByPhone(gomock.Any(), gomock.Eq(user.Phone)).
+					Times(1).
+					Return([]db.User{user, other}, nil)
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:       "UnknownEmail",
+			identifier: "Nobody@example.com",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				// emails are counted ignoring case
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(_ context.Context, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+						assert.Contains(t, []string{usernameFailureKey("nobody@example.com"), ipFailureKey("")}, arg.Key)
+						return db.LoginFailure{Key: arg.Key, Failures: 1}, nil
+					})
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:       "GetUserByEmailError",
+			identifier: user.Email,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:       "InvalidPhone",
+			identifier: "+0123",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsersByPhone(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:       "InvalidEmail",
+			identifier: "@example.com",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubLoginThrottle(store)
+			store.EXPECT().
+				GetUserTotp(gomock.Any(), gomock.Any()).
+				AnyTimes().
+				Return(db.UserTotp{}, sql.ErrNoRows)
+			store.EXPECT().
+				CreateSession(gomock.Any(), gomock.Any()).
+				AnyTimes().
+				Return(db.Session{}, nil)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{"identifier": tc.identifier, "password": password})
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.status, recorder.Code)
+		})
+	}
+}
+
+func TestUsernameCasing(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+	user.DisplayUsername = strings.ToUpper(user.Username[:1]) + user.Username[1:]
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	stubAuthChecks(store)
+	stubLoginThrottle(store)
+	store.EXPECT().
+		GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+		Times(3).
+		Return(user, nil)
+	store.EXPECT().
+		UpdateUser(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		GetUserTotp(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.UserTotp{}, sql.ErrNoRows)
+	store.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.Session{}, nil)
+
+	server := newTestServer(t, store)
+
+	// logging in with any casing finds the user
+	body, err := json.Marshal(gin.H{"identifier": strings.ToUpper(user.Username), "password": password})
+	assert.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var resp loginResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Username, resp.User.Username)
+	assert.Equal(t, user.DisplayUsername, resp.User.DisplayUsername)
+
+	// so does the path
+	req, err = http.NewRequest(http.MethodGet, "/users/"+user.DisplayUsername, nil)
+	assert.NoError(t, err)
+	addAuthHeader(t, req, server.tokenMaker, authTypeBearer, user.Username, time.Minute)
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// tokens issued before usernames were lowercased still belong to the user
+	body, err = json.Marshal(gin.H{"fullname": user.FullName})
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, "/users/"+user.Username, bytes.NewReader(body))
+	assert.NoError(t, err)
+	addAuthHeader(t, req, server.tokenMaker, authTypeBearer, user.DisplayUsername, time.Minute)
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+// countingHasher counts the password checks of the hasher it wraps.
+type countingHasher struct {
+	util.PasswordHasher
+	checks int
+}
+
+func (h *countingHasher) Check(hashedPassword, password string) error {
+	h.checks++
+	return h.PasswordHasher.Check(hashedPassword, password)
+}
+
+func TestLoginUnknownUserLikeWrongPassword(t *testing.T) {
+	user, _ := randomUserWithPassword(t)
+
+	login := func(t *testing.T, found bool) (*httptest.ResponseRecorder, int) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		store := mockdb.NewMockStore(ctrl)
+		stubLoginThrottle(store)
+		if found {
+			store.EXPECT().
+				GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+				Times(1).
+				Return(user, nil)
+		} else {
+			store.EXPECT().
+				GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+				Times(1).
+				Return(db.User{}, sql.ErrNoRows)
+		}
+		store.EXPECT().
+			CreateSession(gomock.Any(), gomock.Any()).
+			Times(0)
+
+		hasher := &countingHasher{PasswordHasher: util.NewArgon2idHasher(util.DefaultArgon2idParams)}
+		server := newTestServer(t, store, WithPasswordHasher(hasher))
+		recorder := httptest.NewRecorder()
+
+		body, err := json.Marshal(gin.H{"identifier": user.Username, "password": "wrong_password"})
+		assert.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
+		assert.NoError(t, err)
+
+		server.router.ServeHTTP(recorder, req)
+		return recorder, hasher.checks
+	}
+
+	wrongPassword, checks := login(t, true)
+	assert.Equal(t, http.StatusUnauthorized, wrongPassword.Code)
+	assert.Equal(t, 1, checks)
+
+	// the unknown user still costs a password check
+	unknownUser, checks := login(t, false)
+	assert.Equal(t, wrongPassword.Code, unknownUser.Code)
+	assert.Equal(t, wrongPassword.Body.String(), unknownUser.Body.String())
+	assert.Equal(t, 1, checks)
+}
+
+func TestPrivateSignup(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+	user.Status = userStatusPendingVerification
+
+	body := gin.H{
+		"username": user.Username,
+		"fullname": user.FullName,
+		"gender":   user.Gender,
+		"age":      user.Age,
+		"email":    user.Email,
+		"phone":    user.Phone,
+		"password": password,
+	}
+
+	testCases := []struct {
+		name        string
+		buildStubs  func(store *mockdb.MockStore)
+		wantMessage string
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+						return db.CreateUserTxResult{User: user}, nil
+					})
+			},
+			wantMessage: "Use this code to verify the email",
+		},
+		{
+			name: "EmailInUse",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateUserTxResult{}, &pgconn.PgError{Code: "23505", ConstraintName: "users_email_idx"})
+			},
+			wantMessage: "it already belongs to a user",
+		},
+		{
+			name: "UsernameTaken",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateUserTxResult{}, &pgconn.PgError{Code: "23505", ConstraintName: "users_username_unique"})
+			},
+			wantMessage: fmt.Sprintf("The username %s is already taken", user.Username),
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			notifier := &testNotifier{}
+			server := newTestServer(t, store, WithNotifier(notifier), WithPrivateSignup(true))
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(body)
+			assert.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(data))
+			assert.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, req)
+
+			// every case answers the same, only the email tells them apart
+			assert.Equal(t, http.StatusAccepted, recorder.Code)
+			var resp signupResponse
+			err = json.Unmarshal(recorder.Body.Bytes(), &resp)
+			assert.NoError(t, err)
+			assert.Equal(t, privateSignupResponse, resp)
+
+			assert.Len(t, notifier.messages, 1)
+			assert.Equal(t, user.Email, notifier.messages[0].To)
+			assert.Contains(t, notifier.messages[0].Body, tc.wantMessage)
+		})
+	}
+}
+
+func TestUpdateUserAPI(t *testing.T) {
+	user := randomUser()
+	newEmail := util.RandomEmail()
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.Email = newEmail
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Eq(db.UpdateUserParams{
+						ID:       user.ID,
+						FullName: user.FullName,
+						Gender:   user.Gender,
+						Email:    newEmail,
+						Phone:    user.Phone,
+					})).
+					Times(1).
+					Return(updated, nil)
+				// the new email gets its own code
+				store.EXPECT().
+					CountEmailVerificationsSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CountEmailVerificationsSinceRow{}, nil)
+				store.EXPECT().
+					CreateEmailVerification(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateEmailVerificationParams) (db.EmailVerification, error) {
+						assert.Equal(t, user.ID, arg.UserID)
+						assert.Equal(t, newEmail, arg.Email)
+						return db.EmailVerification{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Len(t, notifier.messages, 1)
+				assert.Equal(t, newEmail, notifier.messages[0].To)
+			},
+		},
+		{
+			name: "VerificationRateLimited",
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.Email = newEmail
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(updated, nil)
+				store.EXPECT().
+					CountEmailVerificationsSince(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CountEmailVerificationsSinceRow{
+						Sent:        1,
+						FirstSentAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+						LastSentAt:  pgtype.Timestamptz{Time: time.Now(), Valid: true},
+					}, nil)
+				store.EXPECT().
+					CreateEmailVerification(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				// the update stands, the code can be asked for again later
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Empty(t, notifier.messages)
+			},
+		},
+		{
+			name: "EmailInUse",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, &pgconn.PgError{Code: "23505", ConstraintName: "users_email_idx"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, notifier *testNotifier) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), ErrEmailInUse.Error())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthChecks(store)
+			tc.buildStubs(store)
+
+			notifier := &testNotifier{}
+			server := newTestServer(t, store, WithNotifier(notifier))
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{"email": newEmail})
+			assert.NoError(t, err)
+
+			url := fmt.Sprintf("/users/%s", user.Username)
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			assert.NoError(t, err)
+			addAuthHeader(t, req, server.tokenMaker, authTypeBearer, user.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder, notifier)
+		})
+	}
 }
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/mauzec/user-api/db/sqlc"
+)
+
+const (
+	defaultUsersPageSize = 20
+
+	sortCreatedAtAsc  = "created_at"
+	sortCreatedAtDesc = "-created_at"
+)
+
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+// usersCursor is the position after the last user of a page.
+// Users are ordered by created_at and then by id, which is unique.
+type usersCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+func (c usersCursor) encode() string {
+	raw := fmt.Sprintf("%s,%d", c.CreatedAt.Format(time.RFC3339Nano), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeUsersCursor(s string) (usersCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return usersCursor{}, ErrInvalidCursor
+	}
+	createdAt, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return usersCursor{}, ErrInvalidCursor
+	}
+
+	var c usersCursor
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return usersCursor{}, ErrInvalidCursor
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return usersCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+type listUsersRequest struct {
+	// matched against the status in force, so expired restrictions are
+	// listed under the status they lifted to; deleted users are not listed
+	Status      string     `form:"status" binding:"omitempty,oneof=pending_verification active suspended banned locked"`
+	Gender      string     `form:"gender" binding:"omitempty,gender"`
+	MinAge      *int32     `form:"min_age" binding:"omitempty,min=0"`
+	MaxAge      *int32     `form:"max_age" binding:"omitempty,min=0"`
+	CreatedFrom *time.Time `form:"created_from"`
+	CreatedTo   *time.Time `form:"created_to"`
+	// newest first by default
+	Sort     string `form:"sort" binding:"omitempty,oneof=created_at -created_at"`
+	Cursor   string `form:"cursor"`
+	PageSize int32  `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+type listUsersResponse struct {
+	Users []userResponse `json:"users"`
+	// empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// listUsers returns a page of users matching the filters. The next page
+// is requested with the same filters and sort, and the next_cursor.
+func (server *Server) listUsers(ctx *gin.Context) {
+	var req listUsersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+	if req.MinAge != nil && req.MaxAge != nil && *req.MinAge > *req.MaxAge {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("min_age must not be greater than max_age")))
+		return
+	}
+	if req.CreatedFrom != nil && req.CreatedTo != nil && !req.CreatedFrom.Before(*req.CreatedTo) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("created_from must be before created_to")))
+		return
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultUsersPageSize
+	}
+	if req.Sort == "" {
+		req.Sort = sortCreatedAtDesc
+	}
+
+	// one more user tells whether there is a next page
+	args := db.ListUsersParams{
+		Status:    pgtype.Text{String: req.Status, Valid: req.Status != ""},
+		Gender:    pgtype.Text{String: req.Gender, Valid: req.Gender != ""},
+		PageLimit: req.PageSize + 1,
+	}
+	if req.MinAge != nil {
+		args.MinAge = pgtype.Int4{Int32: *req.MinAge, Valid: true}
+	}
+	if req.MaxAge != nil {
+		args.MaxAge = pgtype.Int4{Int32: *req.MaxAge, Valid: true}
+	}
+	if req.CreatedFrom != nil {
+		args.CreatedFrom = pgtype.Timestamptz{Time: *req.CreatedFrom, Valid: true}
+	}
+	if req.CreatedTo != nil {
+		args.CreatedTo = pgtype.Timestamptz{Time: *req.CreatedTo, Valid: true}
+	}
+	if req.Cursor != "" {
+		cursor, err := decodeUsersCursor(req.Cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		args.AfterCreatedAt = pgtype.Timestamptz{Time: cursor.CreatedAt, Valid: true}
+		args.AfterID = pgtype.Int8{Int64: cursor.ID, Valid: true}
+	}
+
+	var users []db.User
+	var err error
+	switch req.Sort {
+	case sortCreatedAtAsc:
+		users, err = server.store.ListUsers(ctx, args)
+	case sortCreatedAtDesc:
+		users, err = server.store.ListUsersDesc(ctx, db.ListUsersDescParams(args))
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	var resp listUsersResponse
+	if len(users) > int(req.PageSize) {
+		users = users[:req.PageSize]
+		last := users[len(users)-1]
+		resp.NextCursor = usersCursor{CreatedAt: last.CreatedAt.Time, ID: last.ID}.encode()
+	}
+	resp.Users = make([]userResponse, 0, len(users))
+	for _, user := range users {
+		resp.Users = append(resp.Users, newUserResponse(user))
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+const maxSearchPage = 50
+
+// likeEscaper escapes the wildcards of LIKE patterns, so they match literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+type searchUsersRequest struct {
+	Query    string `form:"q" binding:"required,min=2,max=64"`
+	Page     int32  `form:"page" binding:"omitempty,min=1"`
+	PageSize int32  `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+type searchUsersResponse struct {
+	Users []userResponse `json:"users"`
+	// zero on the last page
+	NextPage int32 `json:"next_page,omitempty"`
+}
+
+// searchUsers finds users whose name, username, email or phone contain the
+// query or are similar to it, the most relevant first.
+func (server *Server) searchUsers(ctx *gin.Context) {
+	var req searchUsersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(ErrInvalidRequest))
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultUsersPageSize
+	}
+	// ranked results can not use keyset pagination, so deep pages are cut off
+	if req.Page > maxSearchPage {
+		ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("page must not be greater than %d", maxSearchPage)))
+		return
+	}
+
+	query := strings.TrimSpace(req.Query)
+	if len(query) < 2 {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("query must have at least 2 characters")))
+		return
+	}
+
+	users, err := server.store.SearchUsers(ctx, db.SearchUsersParams{
+		Pattern:    "%" + likeEscaper.Replace(query) + "%",
+		Query:      query,
+		PageLimit:  req.PageSize + 1,
+		PageOffset: (req.Page - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(ErrInternalServerError))
+		return
+	}
+
+	var resp searchUsersResponse
+	if len(users) > int(req.PageSize) {
+		users = users[:req.PageSize]
+		if req.Page < maxSearchPage {
+			resp.NextPage = req.Page + 1
+		}
+	}
+	resp.Users = make([]userResponse, 0, len(users))
+	for _, user := range users {
+		resp.Users = append(resp.Users, newUserResponse(user))
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/mauzec/user-api/db/mock"
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestUsersCursor(t *testing.T) {
+	cursor := usersCursor{CreatedAt: time.Now().UTC().Truncate(time.Microsecond), ID: 42}
+
+	got, err := decodeUsersCursor(cursor.encode())
+	assert.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(got.CreatedAt))
+	assert.Equal(t, cursor.ID, got.ID)
+
+	for _, invalid := range []string{"", "!!", "bm8tY29tbWE", "eCw0Mg"} {
+		_, err = decodeUsersCursor(invalid)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	}
+}
+
+func TestListUsersAPI(t *testing.T) {
+	users := make([]db.User, 3)
+	for i := range users {
+		users[i] = randomUser()
+		createdAt := time.Now().UTC().Truncate(time.Microsecond).Add(-time.Duration(i) * time.Hour)
+		users[i].CreatedAt = pgtype.Timestamptz{Time: createdAt, Valid: true}
+	}
+	cursor := usersCursor{CreatedAt: users[1].CreatedAt.Time, ID: users[1].ID}
+
+	testCases := []struct {
+		name          string
+		query         url.Values
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "FirstPage",
+			query: url.Values{"page_size": {"2"}},
+			role:  util.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsersDesc(gomock.Any(), gomock.Eq(db.ListUsersDescParams{PageLimit: 3})).
+					Times(1).
+					Return(users, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp listUsersResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Len(t, resp.Users, 2)
+				assert.Equal(t, users[0].Username, resp.Users[0].Username)
+
+				next, err := decodeUsersCursor(resp.NextCursor)
+				assert.NoError(t, err)
+				assert.True(t, cursor.CreatedAt.Equal(next.CreatedAt))
+				assert.Equal(t, cursor.ID, next.ID)
+			},
+		},
+		{
+			name: "LastPageWithFilters",
+			query: url.Values{
+				"sort":         {"created_at"},
+				"cursor":       {cursor.encode()},
+				"status":       {userStatusActive},
+				"gender":       {"M"},
+				"min_age":      {"20"},
+				"max_age":      {"30"},
+				"created_from": {"2024-01-01T00:00:00Z"},
+			},
+			role: util.RoleModerator,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsers(gomock.Any(), gomock.Eq(db.ListUsersParams{
+						Status:         pgtype.Text{String: userStatusActive, Valid: true},
+						Gender:         pgtype.Text{String: "M", Valid: true},
+						MinAge:         pgtype.Int4{Int32: 20, Valid: true},
+						MaxAge:         pgtype.Int4{Int32: 30, Valid: true},
+						CreatedFrom:    pgtype.Timestamptz{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+						AfterCreatedAt: pgtype.Timestamptz{Time: cursor.CreatedAt, Valid: true},
+						AfterID:        pgtype.Int8{Int64: cursor.ID, Valid: true},
+						PageLimit:      defaultUsersPageSize + 1,
+					})).
+					Times(1).
+					Return(users[2:], nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp listUsersResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Len(t, resp.Users, 1)
+				assert.Empty(t, resp.NextCursor)
+			},
+		},
+		{
+			name:  "NotModerator",
+			query: url.Values{},
+			role:  util.RoleUser,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsersDesc(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidCursor",
+			query: url.Values{"cursor": {"invalid"}},
+			role:  util.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsersDesc(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidAgeRange",
+			query: url.Values{"min_age": {"40"}, "max_age": {"30"}},
+			role:  util.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsersDesc(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidSort",
+			query: url.Values{"sort": {"age"}},
+			role:  util.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsersDesc(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidStatus",
+			query: url.Values{"status": {"activ"}},
+			role:  util.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsersDesc(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			// deleted users are never listed
+			name:  "DeletedStatus",
+			query: url.Values{"status": {userStatusDeleted}},
+			role:  util.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsersDesc(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: url.Values{},
+			role:  util.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListUsersDesc(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthChecks(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodGet, "/users?"+tc.query.Encode(), nil)
+			assert.NoError(t, err)
+
+			addRoleAuthHeader(t, req, server.tokenMaker, authTypeBearer, "staff", tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestSearchUsersAPI(t *testing.T) {
+	users := []db.User{randomUser(), randomUser(), randomUser()}
+
+	testCases := []struct {
+		name          string
+		query         url.Values
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: url.Values{"q": {" 50%_off "}, "page": {"2"}, "page_size": {"2"}},
+			role:  util.RoleModerator,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SearchUsers(gomock.Any(), gomock.Eq(db.SearchUsersParams{
+						Pattern:    `%50\%\_off%`,
+						Query:      "50%_off",
+						PageLimit:  3,
+						PageOffset: 2,
+					})).
+					Times(1).
+					Return(users, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp searchUsersResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Len(t, resp.Users, 2)
+				assert.Equal(t, int32(3), resp.NextPage)
+			},
+		},
+		{
+			name:  "LastPage",
+			query: url.Values{"q": {"john"}},
+			role:  util.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SearchUsers(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(users, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp searchUsersResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Len(t, resp.Users, 3)
+				assert.Zero(t, resp.NextPage)
+			},
+		},
+		{
+			name:  "NotModerator",
+			query: url.Values{"q": {"john"}},
+			role:  util.RoleUser,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SearchUsers(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:  "BlankQuery",
+			query: url.Values{"q": {"   "}},
+			role:  util.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SearchUsers(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "PageTooDeep",
+			query: url.Values{"q": {"john"}, "page": {"51"}},
+			role:  util.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SearchUsers(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthChecks(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodGet, "/users/search?"+tc.query.Encode(), nil)
+			assert.NoError(t, err)
+
+			addRoleAuthHeader(t, req, server.tokenMaker, authTypeBearer, "staff", tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, req)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
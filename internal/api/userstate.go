@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	db "github.com/mauzec/user-api/db/sqlc"
+	"github.com/mauzec/user-api/internal/token"
+)
+
+const (
+	// how long a password or status change on another instance can go unnoticed
+	userStateCacheTTL = 30 * time.Second
+	// expired entries are swept once the cache grows past this size
+	userStateCacheSweepSize = 10000
+)
+
+var (
+	ErrPasswordChanged = errors.New("password was changed after the token was issued")
+	ErrTokensRevoked   = errors.New("tokens of the user were revoked after the token was issued")
+)
+
+type userStateEntry struct {
+	state     db.GetUserAuthStateRow
+	fetchedAt time.Time
+}
+
+// userStateCache remembers when users last changed their passwords or
+// revoked all their tokens and their statuses, so tokens issued before a
+// password change or a revocation and tokens of users kept out by their
+// status can be rejected without reading the user on every request.
+type userStateCache struct {
+	store db.Store
+
+	mu      sync.RWMutex
+	entries map[string]userStateEntry
+}
+
+func newUserStateCache(store db.Store) *userStateCache {
+	return &userStateCache{
+		store:   store,
+		entries: make(map[string]userStateEntry),
+	}
+}
+
+// check is a payloadCheck rejecting tokens issued before the last password
+// change or revocation of all tokens and tokens of users who are
+// suspended, banned or locked.
+func (c *userStateCache) check(ctx context.Context, payload *token.Payload) error {
+	state, err := c.get(ctx, payload.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		log.Println("unable to get user auth state:", err)
+		return ErrInternalServerError
+	}
+
+	if payload.IssuedAt.Before(state.PasswordChangedAt.Time) {
+		return ErrPasswordChanged
+	}
+	if payload.IssuedAt.Before(state.TokensValidAfter.Time) {
+		return ErrTokensRevoked
+	}
+	return statusError(currentStatus(state.Status, state.StatusExpiresAt, state.EmailVerifiedAt))
+}
+
+func (c *userStateCache) get(ctx context.Context, username string) (db.GetUserAuthStateRow, error) {
+	c.mu.RLock()
+	entry, ok := c.entries[username]
+	c.mu.RUnlock()
+	if ok && time.Since(entry.fetchedAt) < userStateCacheTTL {
+		return entry.state, nil
+	}
+
+	state, err := c.store.GetUserAuthState(ctx, username)
+	if err != nil {
+		return db.GetUserAuthStateRow{}, err
+	}
+
+	c.put(username, state)
+	return state, nil
+}
+
+// set records a password, revocation or status change made by this instance, so it is
+// enforced right away instead of after the cache entry expires.
+func (c *userStateCache) set(user db.User) {
+	c.put(user.Username, db.GetUserAuthStateRow{
+		PasswordChangedAt: user.PasswordChangedAt,
+		Status:            user.Status,
+		StatusExpiresAt:   user.StatusExpiresAt,
+		EmailVerifiedAt:   user.EmailVerifiedAt,
+		TokensValidAfter:  user.TokensValidAfter,
+	})
+}
+
+func (c *userStateCache) put(username string, state db.GetUserAuthStateRow) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= userStateCacheSweepSize {
+		for name, entry := range c.entries {
+			if time.Since(entry.fetchedAt) >= userStateCacheTTL {
+				delete(c.entries, name)
+			}
+		}
+	}
+	c.entries[username] = userStateEntry{state: state, fetchedAt: time.Now()}
+}
+
+// forget drops the cached state of a user who is gone, so the next check
+// reads the user again.
+func (c *userStateCache) forget(username string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, username)
+}
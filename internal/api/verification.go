@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// verificationLimitWindow is the window of VerificationLimits.DailyLimit
+const verificationLimitWindow = 24 * time.Hour
+
+var ErrVerificationRateLimited = errors.New("too many verification codes requested, try again later")
+
+// VerificationLimits bounds how often verification codes are sent to a
+// user, every code costs an email or a text message. Password reset tokens
+// are limited the same, counted apart from the codes. A zero limit does not
+// limit anything.
+type VerificationLimits struct {
+	// the least time between two codes
+	ResendInterval time.Duration
+	// codes sent within a day at most
+	DailyLimit int
+}
+
+// DefaultVerificationLimits are used unless set by WithVerificationLimits.
+var DefaultVerificationLimits = VerificationLimits{
+	ResendInterval: time.Minute,
+	DailyLimit:     10,
+}
+
+// since returns where the window of the daily limit starts.
+func (limits VerificationLimits) since() pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: time.Now().Add(-verificationLimitWindow), Valid: true}
+}
+
+// wait returns how long to wait before sending another code, given the
+// codes sent to the user within the last day.
+func (limits VerificationLimits) wait(sent int64, firstSentAt, lastSentAt pgtype.Timestamptz) time.Duration {
+	if sent == 0 {
+		return 0
+	}
+	var wait time.Duration
+	if limits.ResendInterval > 0 {
+		wait = time.Until(lastSentAt.Time.Add(limits.ResendInterval))
+	}
+	if limits.DailyLimit > 0 && sent >= int64(limits.DailyLimit) {
+		wait = max(wait, time.Until(firstSentAt.Time.Add(verificationLimitWindow)))
+	}
+	return max(wait, 0)
+}
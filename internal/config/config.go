@@ -15,7 +15,94 @@ type Config struct {
 	TokenType         TokenType `mapstructure:"TOKEN_TYPE"`
 	TokenSymmetricKey string    `mapstructure:"TOKEN_SYMMETRIC_KEY"`
 
-	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	// keyring for PasetoS tokens as comma separated "id:key" pairs;
+	// when set, it replaces TOKEN_SYMMETRIC_KEY and is reloaded on SIGHUP
+	TokenSymmetricKeys string `mapstructure:"TOKEN_SYMMETRIC_KEYS"`
+	TokenActiveKeyID   string `mapstructure:"TOKEN_ACTIVE_KEY_ID"`
+
+	// PEM files with the ed25519 key pair used by PasetoP tokens
+	TokenPrivateKeyFile string `mapstructure:"TOKEN_PRIVATE_KEY_FILE"`
+	TokenPublicKeyFile  string `mapstructure:"TOKEN_PUBLIC_KEY_FILE"`
+
+	// key of the stored hashes of phone verification codes, at least 32
+	// bytes; the same on every instance
+	CodeHashKey string `mapstructure:"CODE_HASH_KEY"`
+
+	AccessTokenDuration  time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+
+	PasswordResetTokenDuration time.Duration `mapstructure:"PASSWORD_RESET_TOKEN_DURATION"`
+	EmailVerificationDuration  time.Duration `mapstructure:"EMAIL_VERIFICATION_DURATION"`
+	PhoneVerificationDuration  time.Duration `mapstructure:"PHONE_VERIFICATION_DURATION"`
+	MfaTokenDuration           time.Duration `mapstructure:"MFA_TOKEN_DURATION"`
+
+	// whether users may log in before verifying their emails
+	AllowUnverifiedLogin bool `mapstructure:"ALLOW_UNVERIFIED_LOGIN"`
+	// whether signups answer the same for taken usernames and emails and
+	// tell about them by email, so they cannot be used to find users
+	PrivateSignup bool `mapstructure:"PRIVATE_SIGNUP"`
+
+	// the least time between two verification codes or password reset
+	// tokens sent to a user, and how many can be sent within a day; zero
+	// does not limit
+	VerificationResendInterval time.Duration `mapstructure:"VERIFICATION_RESEND_INTERVAL"`
+	VerificationDailyLimit     int           `mapstructure:"VERIFICATION_DAILY_LIMIT"`
+
+	// how long deleted users can be restored, and how often the ones
+	// deleted longer ago are purged; a zero interval turns purging off
+	UserDeletionGracePeriod time.Duration `mapstructure:"USER_DELETION_GRACE_PERIOD"`
+	UserPurgeInterval       time.Duration `mapstructure:"USER_PURGE_INTERVAL"`
+
+	// Argon2id cost of new password hashes, memory in KiB; zero keeps the
+	// default, hashes made with other params are replaced on login
+	PasswordHashMemory      uint32 `mapstructure:"PASSWORD_HASH_MEMORY"`
+	PasswordHashIterations  uint32 `mapstructure:"PASSWORD_HASH_ITERATIONS"`
+	PasswordHashParallelism uint8  `mapstructure:"PASSWORD_HASH_PARALLELISM"`
+
+	// rules of new passwords, a zero length is no limit
+	PasswordMinLength     int  `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMaxLength     int  `mapstructure:"PASSWORD_MAX_LENGTH"`
+	PasswordRequireLower  bool `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireUpper  bool `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireDigit  bool `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol bool `mapstructure:"PASSWORD_REQUIRE_SYMBOL"`
+	// a breached password list or bloom filter file, new passwords found
+	// in it are rejected; empty turns the check off
+	PasswordBreachedFile string `mapstructure:"PASSWORD_BREACHED_FILE"`
+
+	// failed logins of a username or an ip within the window count towards
+	// the backoff, each attempt past the threshold waits twice as long as
+	// the one before; a zero threshold turns that backoff off
+	LoginFailureWindow      time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	LoginBackoffThreshold   int           `mapstructure:"LOGIN_BACKOFF_THRESHOLD"`
+	LoginIPBackoffThreshold int           `mapstructure:"LOGIN_IP_BACKOFF_THRESHOLD"`
+	LoginBackoffBase        time.Duration `mapstructure:"LOGIN_BACKOFF_BASE"`
+	LoginBackoffMax         time.Duration `mapstructure:"LOGIN_BACKOFF_MAX"`
+	// failed logins of a username after which logging in as it is refused for a while
+	LoginLockoutThreshold int           `mapstructure:"LOGIN_LOCKOUT_THRESHOLD"`
+	LoginLockoutDuration  time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+
+	// rate limits of the route groups as "requests/period", e.g. "10/1m";
+	// empty or "0" turns the limit of the group off
+	RateLimitSignup string `mapstructure:"RATE_LIMIT_SIGNUP"`
+	RateLimitLogin  string `mapstructure:"RATE_LIMIT_LOGIN"`
+	RateLimitRead   string `mapstructure:"RATE_LIMIT_READ"`
+	RateLimitWrite  string `mapstructure:"RATE_LIMIT_WRITE"`
+	RateLimitAdmin  string `mapstructure:"RATE_LIMIT_ADMIN"`
+
+	// where user notifications go: "log", "file" or "smtp"
+	Notifier     string `mapstructure:"NOTIFIER"`
+	NotifierFile string `mapstructure:"NOTIFIER_FILE"`
+
+	SMTPHost     string `mapstructure:"SMTP_HOST"`
+	SMTPPort     string `mapstructure:"SMTP_PORT"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD"`
+	SMTPFrom     string `mapstructure:"SMTP_FROM"`
+
+	// where text messages go: "log" or "file"
+	SMSSender     string `mapstructure:"SMS_SENDER"`
+	SMSSenderFile string `mapstructure:"SMS_SENDER_FILE"`
 
 	// if both are non-empty, server will start in https mode
 	TLSCertFile string `mapstructure:"TLS_CERT_FILE"`
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	notifier := NewFileNotifier(path)
+
+	messages := []Message{
+		{To: "a@example.com", Subject: "first", Body: "hello"},
+		{To: "b@example.com", Subject: "second", Body: "world"},
+	}
+	for _, msg := range messages {
+		err := notifier.Notify(context.Background(), msg)
+		assert.NoError(t, err)
+	}
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var got []Message
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var msg Message
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &msg))
+		got = append(got, msg)
+	}
+
+	assert.Len(t, got, len(messages))
+	for i := range messages {
+		assert.Equal(t, messages[i].To, got[i].To)
+		assert.Equal(t, messages[i].Subject, got[i].Subject)
+		assert.Equal(t, messages[i].Body, got[i].Body)
+		assert.NotZero(t, got[i].SentAt)
+	}
+}
+
+func TestBuildMail(t *testing.T) {
+	msg := Message{
+		To:      "a@example.com",
+		Subject: "Verify your email",
+		Body:    "line one\nline two",
+		SentAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	data, err := buildMail("noreply@example.com", msg)
+	assert.NoError(t, err)
+
+	mail := string(data)
+	assert.Contains(t, mail, "From: noreply@example.com\r\n")
+	assert.Contains(t, mail, "To: a@example.com\r\n")
+	assert.Contains(t, mail, "Subject: Verify your email\r\n")
+	assert.Contains(t, mail, "Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n")
+	assert.True(t, strings.HasSuffix(mail, "\r\n\r\nline one\r\nline two\r\n"))
+
+	msg.Subject = "hi\r\nBcc: b@example.com"
+	_, err = buildMail("noreply@example.com", msg)
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestFileSMSSender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sms.jsonl")
+	sender := NewFileSMSSender(path)
+
+	err := sender.SendSMS(context.Background(), SMS{To: "+15555550100", Body: "code: 123456"})
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	var got SMS
+	assert.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, "+15555550100", got.To)
+	assert.Equal(t, "code: 123456", got.Body)
+	assert.NotZero(t, got.SentAt)
+}
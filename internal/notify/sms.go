@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SMS is a text message addressed to a phone number.
+type SMS struct {
+	To     string    `json:"to"`
+	Body   string    `json:"body"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// SMSSender delivers text messages, e.g. phone verification codes.
+type SMSSender interface {
+	SendSMS(ctx context.Context, sms SMS) error
+}
+
+// LogSMSSender writes text messages to the standard logger. Use it only for local development.
+type LogSMSSender struct{}
+
+func NewLogSMSSender() *LogSMSSender {
+	return &LogSMSSender{}
+}
+
+func (s *LogSMSSender) SendSMS(_ context.Context, sms SMS) error {
+	log.Printf("sms to %s: %s", sms.To, sms.Body)
+	return nil
+}
+
+// FileSMSSender appends text messages to a file as JSON lines. Use it only for local development.
+type FileSMSSender struct {
+	file *jsonLinesFile
+}
+
+func NewFileSMSSender(path string) *FileSMSSender {
+	return &FileSMSSender{file: &jsonLinesFile{path: path}}
+}
+
+func (s *FileSMSSender) SendSMS(_ context.Context, sms SMS) error {
+	if sms.SentAt.IsZero() {
+		sms.SentAt = time.Now()
+	}
+	return s.file.append(sms)
+}
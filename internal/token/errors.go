@@ -5,6 +5,8 @@ import "errors"
 var (
 	ErrInvalidToken = errors.New("token is invalid")
 	ErrExpiredToken = errors.New("token is expired")
+	// the token is valid, but can not be used for this
+	ErrWrongTokenType = errors.New("token is of the wrong type")
 
 	ErrPayloadID = errors.New("unexpected error when creating UUID for payload")
 )
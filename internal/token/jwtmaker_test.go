@@ -0,0 +1,157 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTMaker(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	assert.NoError(t, err)
+
+	username := "fallen_angel"
+	duration := time.Minute
+	scopes := []string{util.ScopeUsersRead}
+	issuedAt := time.Now()
+	expiredAt := time.Now().Add(duration)
+
+	token, createdPayload, err := maker.CreateToken(TypeAccess, username, util.RoleUser, scopes, duration)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.NotNil(t, createdPayload)
+
+	p, err := maker.VerifyToken(token)
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+	assert.Equal(t, createdPayload.ID, p.ID)
+	assert.Equal(t, TypeAccess, p.Type)
+	assert.Equal(t, username, p.Username)
+	assert.Equal(t, util.RoleUser, p.Role)
+	assert.Equal(t, scopes, p.Scopes)
+	assert.True(t, p.HasScope(util.ScopeUsersRead))
+	assert.False(t, p.HasScope(util.ScopeUsersWrite))
+	assert.WithinDuration(t, issuedAt, p.IssuedAt, time.Second)
+	assert.WithinDuration(t, expiredAt, p.ExpiredAt, time.Second)
+}
+
+func TestShortJWTKey(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(31))
+	assert.Error(t, err)
+	assert.Nil(t, maker)
+}
+
+func TestExpiredJWTToken(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	assert.NoError(t, err)
+
+	token, _, err := maker.CreateToken(TypeAccess, "fallen_angel", util.RoleUser, nil, -time.Minute)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	p, err := maker.VerifyToken(token)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+	assert.Nil(t, p)
+}
+
+func TestJWTTokenRegisteredClaims(t *testing.T) {
+	key := util.RandomString(32)
+	maker, err := NewJWTMaker(key)
+	assert.NoError(t, err)
+
+	keyFunc := func(t *jwt.Token) (any, error) {
+		return []byte(key), nil
+	}
+
+	token, p, err := maker.CreateToken(TypeAccess, "fallen_angel", util.RoleUser, nil, time.Minute)
+	assert.NoError(t, err)
+
+	// a verifier that knows nothing about the payload
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, keyFunc)
+	assert.NoError(t, err)
+	assert.Equal(t, jwtIssuer, claims["iss"])
+	assert.Equal(t, "fallen_angel", claims["sub"])
+	assert.Equal(t, p.ID.String(), claims["jti"])
+	assert.Contains(t, claims, "exp")
+	assert.Contains(t, claims, "iat")
+	assert.Contains(t, claims, "nbf")
+
+	token, _, err = maker.CreateToken(TypeAccess, "fallen_angel", util.RoleUser, nil, -time.Minute)
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(token, keyFunc)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
+
+func TestJWTTokenNoIssuer(t *testing.T) {
+	key := util.RandomString(32)
+	maker, err := NewJWTMaker(key)
+	assert.NoError(t, err)
+
+	p, err := NewPayload(TypeAccess, "fallen_angel", util.RoleUser, nil, time.Minute)
+	assert.NoError(t, err)
+
+	claims := newJWTClaims(p)
+	claims.Issuer = ""
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+	assert.NoError(t, err)
+
+	p, err = maker.VerifyToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Nil(t, p)
+}
+
+func TestJWTTokenAlgNone(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	assert.NoError(t, err)
+
+	p, err := NewPayload(TypeAccess, "fallen_angel", util.RoleUser, nil, time.Minute)
+	assert.NoError(t, err)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, newJWTClaims(p)).
+		SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+
+	p, err = maker.VerifyToken(token)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Nil(t, p)
+}
+
+func TestJWTTokenOtherAlg(t *testing.T) {
+	key := util.RandomString(32)
+	maker, err := NewJWTMaker(key)
+	assert.NoError(t, err)
+
+	p, err := NewPayload(TypeAccess, "fallen_angel", util.RoleUser, nil, time.Minute)
+	assert.NoError(t, err)
+
+	// signed with the right secret, but not with the expected algorithm
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, newJWTClaims(p)).SignedString([]byte(key))
+	assert.NoError(t, err)
+
+	p, err = maker.VerifyToken(token)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Nil(t, p)
+}
+
+func TestJWTTokenWrongKey(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	assert.NoError(t, err)
+	otherMaker, err := NewJWTMaker(util.RandomString(32))
+	assert.NoError(t, err)
+
+	token, _, err := otherMaker.CreateToken(TypeAccess, "fallen_angel", util.RoleUser, nil, time.Minute)
+	assert.NoError(t, err)
+
+	p, err := maker.VerifyToken(token)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Nil(t, p)
+}
@@ -0,0 +1,100 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// LegacyKeyID is the key ID of tokens issued before key rotation was
+// supported, when the footer was always "Mauzec". Registering the old key
+// under this ID keeps such tokens valid.
+const LegacyKeyID = "Mauzec"
+
+var ErrUnknownKeyID = errors.New("unknown key id")
+
+// Keyring holds the symmetric keys used by PasetoSMaker. The active key
+// signs new tokens, while every key in the ring is accepted for
+// verification. It is safe for concurrent use and can be replaced at runtime.
+type Keyring struct {
+	mu       sync.RWMutex
+	activeID string
+	keys     map[string][]byte
+}
+
+func NewKeyring(activeID string, keys map[string][]byte) (*Keyring, error) {
+	keyring := &Keyring{}
+	if err := keyring.Replace(activeID, keys); err != nil {
+		return nil, err
+	}
+	return keyring, nil
+}
+
+// Replace atomically swaps the whole set of keys.
+func (keyring *Keyring) Replace(activeID string, keys map[string][]byte) error {
+	if len(keys) == 0 {
+		return errors.New("keyring must contain at least one key")
+	}
+	if _, ok := keys[activeID]; !ok {
+		return fmt.Errorf("active key %q is not in the keyring", activeID)
+	}
+
+	copied := make(map[string][]byte, len(keys))
+	for id, key := range keys {
+		if id == "" {
+			return errors.New("key id must not be empty")
+		}
+		if len(key) != chacha20poly1305.KeySize {
+			return fmt.Errorf("key %q size must be equal to %v, got %v", id, chacha20poly1305.KeySize, len(key))
+		}
+		copied[id] = append([]byte(nil), key...)
+	}
+
+	keyring.mu.Lock()
+	defer keyring.mu.Unlock()
+	keyring.activeID = activeID
+	keyring.keys = copied
+	return nil
+}
+
+// Active returns the key used to sign new tokens and its ID.
+func (keyring *Keyring) Active() (string, []byte) {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+	return keyring.activeID, keyring.keys[keyring.activeID]
+}
+
+// Key returns the key with the given ID.
+func (keyring *Keyring) Key(id string) ([]byte, error) {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+	key, ok := keyring.keys[id]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	return key, nil
+}
+
+// ParseKeys parses keys written as comma separated "id:key" pairs,
+// e.g. "2025-06:<32 bytes>,2025-01:<32 bytes>".
+func ParseKeys(spec string) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, key, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("key %q must be in the id:key form", pair)
+		}
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("duplicated key id %q", id)
+		}
+		keys[id] = []byte(key)
+	}
+	return keys, nil
+}
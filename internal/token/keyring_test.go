@@ -0,0 +1,109 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKeyring(t *testing.T) {
+	key := []byte(util.RandomString(32))
+
+	t.Run("OK", func(t *testing.T) {
+		keyring, err := NewKeyring("k1", map[string][]byte{"k1": key})
+		assert.NoError(t, err)
+
+		id, activeKey := keyring.Active()
+		assert.Equal(t, "k1", id)
+		assert.Equal(t, key, activeKey)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		keyring, err := NewKeyring("k1", nil)
+		assert.Error(t, err)
+		assert.Nil(t, keyring)
+	})
+
+	t.Run("UnknownActiveKey", func(t *testing.T) {
+		keyring, err := NewKeyring("k2", map[string][]byte{"k1": key})
+		assert.Error(t, err)
+		assert.Nil(t, keyring)
+	})
+
+	t.Run("WrongKeySize", func(t *testing.T) {
+		keyring, err := NewKeyring("k1", map[string][]byte{"k1": []byte("short")})
+		assert.Error(t, err)
+		assert.Nil(t, keyring)
+	})
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys("k2:12345678901234567890123456789012, k1:abcdefghijklmnopqrstuvwxyzabcdef")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, []byte("12345678901234567890123456789012"), keys["k2"])
+	assert.Equal(t, []byte("abcdefghijklmnopqrstuvwxyzabcdef"), keys["k1"])
+
+	_, err = ParseKeys("no_separator")
+	assert.Error(t, err)
+
+	_, err = ParseKeys("k1:a,k1:b")
+	assert.Error(t, err)
+}
+
+func TestPasetoSMakerKeyRotation(t *testing.T) {
+	oldKey := []byte(util.RandomString(32))
+	newKey := []byte(util.RandomString(32))
+
+	keyring, err := NewKeyring("old", map[string][]byte{"old": oldKey})
+	assert.NoError(t, err)
+	maker := NewPasetoSMakerWithKeyring(keyring)
+
+	oldToken, _, err := maker.CreateToken(TypeAccess, "fallen_angel", util.RoleUser, nil, time.Minute)
+	assert.NoError(t, err)
+
+	// rotate: new key signs, old key still verifies
+	err = keyring.Replace("new", map[string][]byte{"new": newKey, "old": oldKey})
+	assert.NoError(t, err)
+
+	newToken, _, err := maker.CreateToken(TypeAccess, "fallen_angel", util.RoleUser, nil, time.Minute)
+	assert.NoError(t, err)
+
+	_, err = maker.VerifyToken(oldToken)
+	assert.NoError(t, err)
+	_, err = maker.VerifyToken(newToken)
+	assert.NoError(t, err)
+
+	// retire the old key
+	err = keyring.Replace("new", map[string][]byte{"new": newKey})
+	assert.NoError(t, err)
+
+	p, err := maker.VerifyToken(oldToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Nil(t, p)
+	_, err = maker.VerifyToken(newToken)
+	assert.NoError(t, err)
+}
+
+func TestPasetoSMakerLegacyToken(t *testing.T) {
+	key := util.RandomString(32)
+
+	// tokens from NewPasetoSMaker keep the old "Mauzec" footer
+	legacyMaker, err := NewPasetoSMaker(key)
+	assert.NoError(t, err)
+	token, _, err := legacyMaker.CreateToken(TypeAccess, "fallen_angel", util.RoleUser, nil, time.Minute)
+	assert.NoError(t, err)
+
+	keyring, err := NewKeyring("new", map[string][]byte{
+		"new":       []byte(util.RandomString(32)),
+		LegacyKeyID: []byte(key),
+	})
+	assert.NoError(t, err)
+	maker := NewPasetoSMakerWithKeyring(keyring)
+
+	p, err := maker.VerifyToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "fallen_angel", p.Username)
+}
@@ -1,8 +1,18 @@
 package token
 
-import "time"
+import (
+	"crypto/ed25519"
+	"time"
+)
 
 type Maker interface {
-	CreateToken(username string, duration time.Duration) (string, error)
+	CreateToken(tokenType Type, username string, role string, scopes []string, duration time.Duration) (string, *Payload, error)
 	VerifyToken(token string) (*Payload, error)
 }
+
+// PublicKeyMaker is a Maker whose tokens can be verified
+// by other services knowing only the public key.
+type PublicKeyMaker interface {
+	Maker
+	PublicKey() ed25519.PublicKey
+}
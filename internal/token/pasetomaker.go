@@ -1,34 +1,48 @@
 package token
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/o1egl/paseto/v2"
-	"golang.org/x/crypto/chacha20poly1305"
 )
 
+// PasetoSMaker creates PASETO v2.local tokens. The ID of the key that
+// encrypted a token is put in its footer.
 type PasetoSMaker struct {
-	paseto *paseto.V2
-	key    []byte
+	paseto  *paseto.V2
+	keyring *Keyring
 }
 
-func (maker *PasetoSMaker) CreateToken(username string, duration time.Duration) (string, error) {
-	p, err := NewPayload(username, duration)
+func (maker *PasetoSMaker) CreateToken(tokenType Type, username string, role string, scopes []string, duration time.Duration) (string, *Payload, error) {
+	p, err := NewPayload(tokenType, username, role, scopes, duration)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	token, err := maker.paseto.Encrypt(maker.key, p, "Mauzec")
-	return token, err
+	keyID, key := maker.keyring.Active()
+	token, err := maker.paseto.Encrypt(key, p, keyID)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, p, nil
 }
 
 func (maker *PasetoSMaker) VerifyToken(token string) (*Payload, error) {
+	var keyID string
+	err := paseto.ParseFooter(token, &keyID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	key, err := maker.keyring.Key(keyID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
 	p := &Payload{}
 
 	var footer string
-	err := maker.paseto.Decrypt(token, maker.key, p, &footer)
-	if err != nil || footer != "Mauzec" {
+	err = maker.paseto.Decrypt(token, key, p, &footer)
+	if err != nil || footer != keyID {
 		return nil, ErrInvalidToken
 	}
 	err = p.Valid()
@@ -38,9 +52,17 @@ func (maker *PasetoSMaker) VerifyToken(token string) (*Payload, error) {
 	return p, nil
 }
 
+// NewPasetoSMaker creates a maker with a single key.
 func NewPasetoSMaker(key string) (*PasetoSMaker, error) {
-	if len(key) < chacha20poly1305.KeySize {
-		return nil, fmt.Errorf("key size must be equal to %v, got %v", chacha20poly1305.KeySize, len(key))
+	keyring, err := NewKeyring(LegacyKeyID, map[string][]byte{LegacyKeyID: []byte(key)})
+	if err != nil {
+		return nil, err
 	}
-	return &PasetoSMaker{paseto.NewV2(), []byte(key)}, nil
+	return NewPasetoSMakerWithKeyring(keyring), nil
+}
+
+// NewPasetoSMakerWithKeyring creates a maker that picks keys from keyring,
+// so keys can be rotated without recreating the maker.
+func NewPasetoSMakerWithKeyring(keyring *Keyring) *PasetoSMaker {
+	return &PasetoSMaker{paseto.NewV2(), keyring}
 }
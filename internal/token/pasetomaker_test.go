@@ -14,18 +14,26 @@ func TestPasetoSMaker(t *testing.T) {
 
 	username := "fallen_angel"
 	duration := time.Minute
+	scopes := []string{util.ScopeUsersRead}
 	issuedAt := time.Now()
 	expiredAt := time.Now().Add(duration)
 
-	token, err := maker.CreateToken(username, duration)
+	token, createdPayload, err := maker.CreateToken(TypeAccess, username, util.RoleUser, scopes, duration)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
+	assert.NotNil(t, createdPayload)
 
 	p, err := maker.VerifyToken(token)
 	assert.NoError(t, err)
+	assert.Equal(t, createdPayload.ID, p.ID)
 	assert.NotNil(t, p)
 	assert.NotZero(t, p.ID)
+	assert.Equal(t, TypeAccess, p.Type)
 	assert.Equal(t, username, p.Username)
+	assert.Equal(t, util.RoleUser, p.Role)
+	assert.Equal(t, scopes, p.Scopes)
+	assert.True(t, p.HasScope(util.ScopeUsersRead))
+	assert.False(t, p.HasScope(util.ScopeUsersWrite))
 	assert.WithinDuration(t, issuedAt, p.IssuedAt, time.Second)
 	assert.WithinDuration(t, expiredAt, p.ExpiredAt, time.Second)
 }
@@ -37,7 +45,7 @@ func TestExpiredPasetoSToken(t *testing.T) {
 	username := "fallen_angel"
 	duration := time.Nanosecond
 
-	token, err := maker.CreateToken(username, duration)
+	token, _, err := maker.CreateToken(TypeAccess, username, util.RoleUser, nil, duration)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 
@@ -0,0 +1,141 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/o1egl/paseto/v2"
+)
+
+// PasetoPMaker signs tokens with an Ed25519 private key (PASETO v2.public),
+// so any service holding the public key can verify them.
+type PasetoPMaker struct {
+	paseto     *paseto.V2
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+func (maker *PasetoPMaker) CreateToken(tokenType Type, username string, role string, scopes []string, duration time.Duration) (string, *Payload, error) {
+	p, err := NewPayload(tokenType, username, role, scopes, duration)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err := maker.paseto.Sign(maker.privateKey, p, "Mauzec")
+	if err != nil {
+		return "", nil, err
+	}
+	return token, p, nil
+}
+
+func (maker *PasetoPMaker) VerifyToken(token string) (*Payload, error) {
+	p := &Payload{}
+
+	var footer string
+	err := maker.paseto.Verify(token, maker.publicKey, p, &footer)
+	if err != nil || footer != "Mauzec" {
+		return nil, ErrInvalidToken
+	}
+	err = p.Valid()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (maker *PasetoPMaker) PublicKey() ed25519.PublicKey {
+	return maker.publicKey
+}
+
+func NewPasetoPMaker(privateKey ed25519.PrivateKey, publicKey ed25519.PublicKey) (*PasetoPMaker, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("private key size must be equal to %v, got %v", ed25519.PrivateKeySize, len(privateKey))
+	}
+	derived, _ := privateKey.Public().(ed25519.PublicKey)
+	if publicKey == nil {
+		publicKey = derived
+	}
+	if !publicKey.Equal(derived) {
+		return nil, errors.New("public key does not match private key")
+	}
+	return &PasetoPMaker{paseto.NewV2(), privateKey, publicKey}, nil
+}
+
+// NewPasetoPMakerFromFiles loads a PKCS#8 private key and an optional PKIX
+// public key, both PEM encoded. If publicKeyFile is empty, the public key
+// is derived from the private one.
+func NewPasetoPMakerFromFiles(privateKeyFile, publicKeyFile string) (*PasetoPMaker, error) {
+	privateKey, err := LoadEd25519PrivateKey(privateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var publicKey ed25519.PublicKey
+	if publicKeyFile != "" {
+		publicKey, err = LoadEd25519PublicKey(publicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewPasetoPMaker(privateKey, publicKey)
+}
+
+func LoadEd25519PrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key %s: %w", path, err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an ed25519 key", path)
+	}
+	return privateKey, nil
+}
+
+func LoadEd25519PublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key %s: %w", path, err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an ed25519 key", path)
+	}
+	return publicKey, nil
+}
+
+// EncodeEd25519PublicKey returns the public key as a PKIX PEM block.
+func EncodeEd25519PublicKey(publicKey ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	return block, nil
+}
+
+var _ PublicKeyMaker = (*PasetoPMaker)(nil)
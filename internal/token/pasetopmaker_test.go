@@ -0,0 +1,124 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mauzec/user-api/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPasetoPMaker(t *testing.T) *PasetoPMaker {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	maker, err := NewPasetoPMaker(privateKey, nil)
+	assert.NoError(t, err)
+	return maker
+}
+
+func TestPasetoPMaker(t *testing.T) {
+	maker := newTestPasetoPMaker(t)
+
+	username := "fallen_angel"
+	duration := time.Minute
+	scopes := []string{util.ScopeUsersRead}
+	issuedAt := time.Now()
+	expiredAt := time.Now().Add(duration)
+
+	token, createdPayload, err := maker.CreateToken(TypeAccess, username, util.RoleUser, scopes, duration)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.NotNil(t, createdPayload)
+
+	p, err := maker.VerifyToken(token)
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+	assert.Equal(t, createdPayload.ID, p.ID)
+	assert.Equal(t, TypeAccess, p.Type)
+	assert.Equal(t, username, p.Username)
+	assert.Equal(t, util.RoleUser, p.Role)
+	assert.Equal(t, scopes, p.Scopes)
+	assert.True(t, p.HasScope(util.ScopeUsersRead))
+	assert.False(t, p.HasScope(util.ScopeUsersWrite))
+	assert.WithinDuration(t, issuedAt, p.IssuedAt, time.Second)
+	assert.WithinDuration(t, expiredAt, p.ExpiredAt, time.Second)
+}
+
+func TestExpiredPasetoPToken(t *testing.T) {
+	maker := newTestPasetoPMaker(t)
+
+	token, _, err := maker.CreateToken(TypeAccess, "fallen_angel", util.RoleUser, nil, -time.Minute)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	p, err := maker.VerifyToken(token)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+	assert.Nil(t, p)
+}
+
+func TestPasetoPTokenOtherKey(t *testing.T) {
+	maker := newTestPasetoPMaker(t)
+	otherMaker := newTestPasetoPMaker(t)
+
+	token, _, err := otherMaker.CreateToken(TypeAccess, "fallen_angel", util.RoleUser, nil, time.Minute)
+	assert.NoError(t, err)
+
+	p, err := maker.VerifyToken(token)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Nil(t, p)
+}
+
+func TestPasetoPMakerMismatchedKeys(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	maker, err := NewPasetoPMaker(privateKey, otherPublicKey)
+	assert.Error(t, err)
+	assert.Nil(t, maker)
+}
+
+func TestPasetoPMakerFromFiles(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	privateKeyFile := filepath.Join(dir, "private.pem")
+	publicKeyFile := filepath.Join(dir, "public.pem")
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.NoError(t, err)
+	err = os.WriteFile(privateKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	assert.NoError(t, err)
+
+	publicPEM, err := EncodeEd25519PublicKey(publicKey)
+	assert.NoError(t, err)
+	err = os.WriteFile(publicKeyFile, publicPEM, 0o644)
+	assert.NoError(t, err)
+
+	maker, err := NewPasetoPMakerFromFiles(privateKeyFile, publicKeyFile)
+	assert.NoError(t, err)
+	assert.True(t, publicKey.Equal(maker.PublicKey()))
+
+	t.Run("NoPublicKeyFile", func(t *testing.T) {
+		maker, err := NewPasetoPMakerFromFiles(privateKeyFile, "")
+		assert.NoError(t, err)
+		assert.True(t, publicKey.Equal(maker.PublicKey()))
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		maker, err := NewPasetoPMakerFromFiles(filepath.Join(dir, "missing.pem"), "")
+		assert.Error(t, err)
+		assert.Nil(t, maker)
+	})
+}
@@ -1,43 +1,36 @@
 package token
 
 import (
+	"slices"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+// Type tells what a token may be used for.
+type Type string
+
+const (
+	// TypeAccess tokens authorize requests
+	TypeAccess Type = "access"
+	// TypeRefresh tokens can only be traded for new access tokens
+	TypeRefresh Type = "refresh"
+)
+
 // Payload represents the structure of the part of data contained in a token.
 type Payload struct {
 	ID        uuid.UUID `json:"id"`
+	Type      Type      `json:"type"`
 	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Scopes    []string  `json:"scopes"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
 
-func (p *Payload) GetExpirationTime() (*jwt.NumericDate, error) {
-	return &jwt.NumericDate{Time: p.ExpiredAt}, nil
-}
-
-func (p *Payload) GetIssuedAt() (*jwt.NumericDate, error) {
-	return &jwt.NumericDate{Time: p.IssuedAt}, nil
-}
-
-func (p *Payload) GetNotBefore() (*jwt.NumericDate, error) {
-	// need to change?
-	return &jwt.NumericDate{Time: p.IssuedAt}, nil
-}
-
-func (p *Payload) GetIssuer() (string, error) {
-	return "fill_me", nil
-}
-
-func (p *Payload) GetSubject() (string, error) {
-	return p.ID.String(), nil
-}
-
-func (p *Payload) GetAudience() (jwt.ClaimStrings, error) {
-	return nil, nil
+// HasScope reports whether the token was granted the scope.
+func (payload *Payload) HasScope(scope string) bool {
+	return slices.Contains(payload.Scopes, scope)
 }
 
 func (payload *Payload) Valid() error {
@@ -47,16 +40,19 @@ func (payload *Payload) Valid() error {
 	return nil
 }
 
-func NewPayload(username string, duration time.Duration) (*Payload, error) {
+func NewPayload(tokenType Type, username string, role string, scopes []string, duration time.Duration) (*Payload, error) {
 	id, err := uuid.NewRandom()
 	if err != nil {
 		return nil, ErrPayloadID
 	}
 
 	return &Payload{
-		id,
-		username,
-		time.Now(),
-		time.Now().Add(duration),
+		ID:        id,
+		Type:      tokenType,
+		Username:  username,
+		Role:      role,
+		Scopes:    scopes,
+		IssuedAt:  time.Now(),
+		ExpiredAt: time.Now().Add(duration),
 	}, nil
 }
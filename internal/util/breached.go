@@ -0,0 +1,231 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// bloomFilterMagic starts every bloom filter file, other files are read as lists
+var bloomFilterMagic = []byte("BLOOM1\n")
+
+// maxBloomFilterHashes bounds the hashes of a filter read from a file, a
+// filter sized by NewBloomFilter needs far fewer, and every lookup
+// computes all of them
+const maxBloomFilterHashes = 64
+
+var ErrInvalidBloomFilter = errors.New("invalid bloom filter file")
+
+// BreachedPasswords tells whether a password is known from a data breach.
+// Passwords are looked up by their SHA-1 digest, the form breach corpora
+// such as Have I Been Pwned publish them in.
+type BreachedPasswords interface {
+	Contains(password string) bool
+}
+
+func breachedPasswordDigest(password string) [sha1.Size]byte {
+	return sha1.Sum([]byte(password))
+}
+
+// BreachedPasswordList keeps every digest in memory, it suits short lists.
+type BreachedPasswordList struct {
+	digests map[[sha1.Size]byte]struct{}
+}
+
+// ReadBreachedPasswordList reads a list with one entry per line. An entry
+// is a plain password, or a hex SHA-1 digest optionally followed by
+// ":count". Empty lines and lines starting with "#" are skipped.
+func ReadBreachedPasswordList(r io.Reader) (*BreachedPasswordList, error) {
+	list := &BreachedPasswordList{digests: make(map[[sha1.Size]byte]struct{})}
+	err := readBreachedPasswords(r, func(digest [sha1.Size]byte) {
+		list.digests[digest] = struct{}{}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (list *BreachedPasswordList) Contains(password string) bool {
+	_, ok := list.digests[breachedPasswordDigest(password)]
+	return ok
+}
+
+func (list *BreachedPasswordList) Len() int {
+	return len(list.digests)
+}
+
+// readBreachedPasswords calls add with the digest of every entry of a list.
+func readBreachedPasswords(r io.Reader, add func(digest [sha1.Size]byte)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		add(parseBreachedPassword(line))
+	}
+	return scanner.Err()
+}
+
+// parseBreachedPassword returns the digest of a list entry. Entries which
+// look like a SHA-1 digest are taken as one.
+func parseBreachedPassword(line string) [sha1.Size]byte {
+	digest, _, _ := strings.Cut(line, ":")
+	if len(digest) == hex.EncodedLen(sha1.Size) {
+		var sum [sha1.Size]byte
+		if _, err := hex.Decode(sum[:], []byte(digest)); err == nil {
+			return sum
+		}
+	}
+	return breachedPasswordDigest(line)
+}
+
+// BloomFilter answers whether a password may be in a breached list without
+// keeping the list. It never misses a listed password, but may report an
+// unlisted one with the false positive rate it was sized for.
+type BloomFilter struct {
+	bits   []byte
+	size   uint64
+	hashes uint32
+}
+
+// NewBloomFilter sizes a filter for n entries with the false positive rate.
+func NewBloomFilter(n int, falsePositiveRate float64) *BloomFilter {
+	n = max(n, 1)
+	size := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	size = max(size, 8)
+	hashes := uint32(math.Max(1, math.Round(float64(size)/float64(n)*math.Ln2)))
+	return &BloomFilter{
+		bits:   make([]byte, (size+7)/8),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+// positions derives the bits of a digest by double hashing.
+func (f *BloomFilter) positions(digest [sha1.Size]byte, yield func(pos uint64) bool) {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+	for i := range uint64(f.hashes) {
+		if !yield((h1 + i*h2) % f.size) {
+			return
+		}
+	}
+}
+
+func (f *BloomFilter) addDigest(digest [sha1.Size]byte) {
+	f.positions(digest, func(pos uint64) bool {
+		f.bits[pos/8] |= 1 << (pos % 8)
+		return true
+	})
+}
+
+// Add adds a password to the filter.
+func (f *BloomFilter) Add(password string) {
+	f.addDigest(breachedPasswordDigest(password))
+}
+
+// AddList adds every entry of a list, see ReadBreachedPasswordList.
+func (f *BloomFilter) AddList(r io.Reader) error {
+	return readBreachedPasswords(r, f.addDigest)
+}
+
+func (f *BloomFilter) Contains(password string) bool {
+	found := true
+	f.positions(breachedPasswordDigest(password), func(pos uint64) bool {
+		found = f.bits[pos/8]&(1<<(pos%8)) != 0
+		return found
+	})
+	return found
+}
+
+// WriteTo writes the filter in the format read by ReadBloomFilter: the
+// magic line, the number of bits and of hashes, then the bits.
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, 0, len(bloomFilterMagic)+12)
+	header = append(header, bloomFilterMagic...)
+	header = binary.BigEndian.AppendUint64(header, f.size)
+	header = binary.BigEndian.AppendUint32(header, f.hashes)
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(f.bits)
+	return int64(n + m), err
+}
+
+// ReadBloomFilter reads a filter written by WriteTo. The size is the length
+// of the filter in bytes, e.g. the size of its file. The header has to agree
+// with it before the bits are allocated, so a broken file can not make the
+// server allocate more than the file holds.
+func ReadBloomFilter(r io.Reader, size int64) (*BloomFilter, error) {
+	headerSize := int64(len(bloomFilterMagic) + 12)
+	if size < headerSize {
+		return nil, ErrInvalidBloomFilter
+	}
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrInvalidBloomFilter
+	}
+	if !bytes.HasPrefix(header, bloomFilterMagic) {
+		return nil, ErrInvalidBloomFilter
+	}
+	header = header[len(bloomFilterMagic):]
+
+	f := &BloomFilter{
+		size:   binary.BigEndian.Uint64(header[0:8]),
+		hashes: binary.BigEndian.Uint32(header[8:12]),
+	}
+	if f.size == 0 || f.size/8+(f.size%8+7)/8 != uint64(size-headerSize) {
+		return nil, ErrInvalidBloomFilter
+	}
+	if f.hashes == 0 || f.hashes > maxBloomFilterHashes || uint64(f.hashes) > f.size {
+		return nil, ErrInvalidBloomFilter
+	}
+	f.bits = make([]byte, size-headerSize)
+	if _, err := io.ReadFull(r, f.bits); err != nil {
+		return nil, ErrInvalidBloomFilter
+	}
+	return f, nil
+}
+
+// LoadBreachedPasswords reads a bloom filter file, or a list file if the
+// file does not start like a bloom filter.
+func LoadBreachedPasswords(path string) (BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	magic, err := reader.Peek(len(bloomFilterMagic))
+	if err == nil && bytes.Equal(magic, bloomFilterMagic) {
+		filter, err := ReadBloomFilter(reader, info.Size())
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", path, err)
+		}
+		return filter, nil
+	}
+
+	list, err := ReadBreachedPasswordList(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	return list, nil
+}
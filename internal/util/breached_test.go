@@ -0,0 +1,145 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the SHA-1 digest of "password", as breach corpora list it
+const passwordSHA1 = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+
+func TestReadBreachedPasswordList(t *testing.T) {
+	list, err := ReadBreachedPasswordList(strings.NewReader(
+		"# passwords\n" +
+			"123456\n" +
+			"\n" +
+			passwordSHA1 + ":9545824\n" +
+			strings.ToLower("7C4A8D09CA3762AF61E59520943DC26494F8941B") + "\n",
+	))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, list.Len())
+
+	assert.True(t, list.Contains("123456"))
+	assert.True(t, list.Contains("password"))
+	assert.False(t, list.Contains("Password"))
+	assert.False(t, list.Contains("# passwords"))
+}
+
+func TestBloomFilter(t *testing.T) {
+	filter := NewBloomFilter(1000, 0.001)
+	for i := range 1000 {
+		filter.Add(fmt.Sprintf("password%d", i))
+	}
+	assert.NoError(t, filter.AddList(strings.NewReader(passwordSHA1+":9545824\n")))
+
+	var buf bytes.Buffer
+	_, err := filter.WriteTo(&buf)
+	assert.NoError(t, err)
+
+	read, err := ReadBloomFilter(&buf, int64(buf.Len()))
+	assert.NoError(t, err)
+	assert.Equal(t, filter, read)
+
+	for i := range 1000 {
+		assert.True(t, read.Contains(fmt.Sprintf("password%d", i)))
+	}
+	assert.True(t, read.Contains("password"))
+
+	falsePositives := 0
+	for i := range 10000 {
+		if read.Contains(RandomString(12) + fmt.Sprint(i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 50)
+}
+
+func TestReadBloomFilterInvalid(t *testing.T) {
+	_, err := ReadBloomFilter(strings.NewReader("123456\n"), 7)
+	assert.ErrorIs(t, err, ErrInvalidBloomFilter)
+
+	var buf bytes.Buffer
+	_, err = NewBloomFilter(10, 0.01).WriteTo(&buf)
+	assert.NoError(t, err)
+	filter := buf.Bytes()
+
+	_, err = ReadBloomFilter(bytes.NewReader(filter[:len(filter)-1]), int64(len(filter)))
+	assert.ErrorIs(t, err, ErrInvalidBloomFilter)
+
+	// headers not matching the size are rejected before anything is allocated
+	bitsAt := len(bloomFilterMagic)
+	hashesAt := bitsAt + 8
+	testCases := []struct {
+		name   string
+		header func(header []byte)
+		size   int
+	}{
+		{
+			name:   "TooManyBits",
+			header: func(header []byte) { binary.BigEndian.PutUint64(header[bitsAt:], math.MaxUint64) },
+			size:   len(filter),
+		},
+		{
+			name:   "NoBits",
+			header: func(header []byte) { binary.BigEndian.PutUint64(header[bitsAt:], 0) },
+			size:   len(filter),
+		},
+		{
+			name:   "TooManyHashes",
+			header: func(header []byte) { binary.BigEndian.PutUint32(header[hashesAt:], math.MaxUint32) },
+			size:   len(filter),
+		},
+		{
+			name:   "NoHashes",
+			header: func(header []byte) { binary.BigEndian.PutUint32(header[hashesAt:], 0) },
+			size:   len(filter),
+		},
+		{
+			name:   "TrailingData",
+			header: func(header []byte) {},
+			size:   len(filter) + 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			broken := bytes.Clone(filter)
+			tc.header(broken)
+			_, err := ReadBloomFilter(bytes.NewReader(broken), int64(tc.size))
+			assert.ErrorIs(t, err, ErrInvalidBloomFilter)
+		})
+	}
+}
+
+func TestLoadBreachedPasswords(t *testing.T) {
+	dir := t.TempDir()
+
+	listPath := filepath.Join(dir, "list.txt")
+	assert.NoError(t, os.WriteFile(listPath, []byte("123456\n"), 0o600))
+	breached, err := LoadBreachedPasswords(listPath)
+	assert.NoError(t, err)
+	assert.IsType(t, &BreachedPasswordList{}, breached)
+	assert.True(t, breached.Contains("123456"))
+
+	filter := NewBloomFilter(10, 0.01)
+	filter.Add("123456")
+	var buf bytes.Buffer
+	_, err = filter.WriteTo(&buf)
+	assert.NoError(t, err)
+	filterPath := filepath.Join(dir, "filter.bloom")
+	assert.NoError(t, os.WriteFile(filterPath, buf.Bytes(), 0o600))
+	breached, err = LoadBreachedPasswords(filterPath)
+	assert.NoError(t, err)
+	assert.IsType(t, &BloomFilter{}, breached)
+	assert.True(t, breached.Contains("123456"))
+
+	_, err = LoadBreachedPasswords(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
@@ -2,19 +2,29 @@ package util
 
 import (
 	"fmt"
-
-	"golang.org/x/crypto/bcrypt"
+	"strings"
 )
 
+// defaultPasswordHasher is used by HashPassword, servers use the hasher they are configured with.
+var defaultPasswordHasher = NewArgon2idHasher(DefaultArgon2idParams)
+
+// HashPassword hashes the password with Argon2id and the default params.
 func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := defaultPasswordHasher.Hash(password)
 	if err != nil {
 		return "", fmt.Errorf("unable to create hashed password: %v", err)
 	}
 
-	return string(hashedPassword), nil
+	return hashedPassword, nil
 }
 
+// CheckPassword checks the password against an Argon2id or a bcrypt hash.
 func CheckPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	switch {
+	case strings.HasPrefix(hashedPassword, argon2idHashPrefix):
+		return checkArgon2idPassword(hashedPassword, password)
+	case isBcryptHash(hashedPassword):
+		return checkBcryptPassword(hashedPassword, password)
+	}
+	return ErrUnknownHashFormat
 }
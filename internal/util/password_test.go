@@ -4,7 +4,6 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func TestHashingPassword(t *testing.T) {
@@ -21,7 +20,7 @@ func TestHashingPassword(t *testing.T) {
 
 	t.Run("WrongPassword", func(t *testing.T) {
 		err = CheckPassword(hashedPassword, "hello")
-		assert.EqualError(t, err, bcrypt.ErrMismatchedHashAndPassword.Error())
+		assert.ErrorIs(t, err, ErrPasswordMismatch)
 	})
 
 	t.Run("OtherHashedPassword", func(t *testing.T) {
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestArgon2idHasher(t *testing.T) {
+	params := Argon2idParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+	hasher := NewArgon2idHasher(params)
+	password := RandomString(10)
+
+	hashedPassword, err := hasher.Hash(password)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hashedPassword, "$argon2id$v=19$m=8192,t=1,p=2$"))
+
+	assert.NoError(t, hasher.Check(hashedPassword, password))
+	assert.ErrorIs(t, hasher.Check(hashedPassword, "hello"), ErrPasswordMismatch)
+	assert.False(t, hasher.NeedsRehash(hashedPassword))
+
+	// hashes keep their own params, so they are checked after the params change
+	stronger := NewArgon2idHasher(Argon2idParams{Memory: 16 * 1024, Iterations: 1, Parallelism: 2})
+	assert.NoError(t, stronger.Check(hashedPassword, password))
+	assert.True(t, stronger.NeedsRehash(hashedPassword))
+
+	longerKey := params
+	longerKey.KeyLength = 64
+	assert.True(t, NewArgon2idHasher(longerKey).NeedsRehash(hashedPassword))
+}
+
+func TestArgon2idHasherBcrypt(t *testing.T) {
+	hasher := NewArgon2idHasher(DefaultArgon2idParams)
+	password := RandomString(10)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	assert.NoError(t, hasher.Check(string(bcryptHash), password))
+	assert.ErrorIs(t, hasher.Check(string(bcryptHash), "hello"), ErrPasswordMismatch)
+	assert.True(t, hasher.NeedsRehash(string(bcryptHash)))
+}
+
+func TestCheckPasswordInvalidHash(t *testing.T) {
+	testCases := []struct {
+		name           string
+		hashedPassword string
+		err            error
+	}{
+		{"Empty", "", ErrUnknownHashFormat},
+		{"Plain", "secret", ErrUnknownHashFormat},
+		{"MissingParts", "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA", ErrInvalidArgon2Hash},
+		{"OtherVersion", "$argon2id$v=16$m=19456,t=2,p=1$c2FsdA$aGFzaA", ErrInvalidArgon2Hash},
+		{"BadParams", "$argon2id$v=19$m=x,t=2,p=1$c2FsdA$aGFzaA", ErrInvalidArgon2Hash},
+		{"BadSalt", "$argon2id$v=19$m=19456,t=2,p=1$!!$aGFzaA", ErrInvalidArgon2Hash},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, CheckPassword(tc.hashedPassword, "secret"), tc.err)
+			assert.True(t, NewArgon2idHasher(DefaultArgon2idParams).NeedsRehash(tc.hashedPassword))
+		})
+	}
+}
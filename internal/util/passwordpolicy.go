@@ -0,0 +1,146 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrPasswordPolicy = errors.New("password does not meet the password policy")
+
+	ErrPasswordTooShort         = errors.New("password is too short")
+	ErrPasswordTooLong          = errors.New("password is too long")
+	ErrPasswordNoLower          = errors.New("password must contain a lowercase letter")
+	ErrPasswordNoUpper          = errors.New("password must contain an uppercase letter")
+	ErrPasswordNoDigit          = errors.New("password must contain a digit")
+	ErrPasswordNoSymbol         = errors.New("password must contain a symbol")
+	ErrPasswordContainsUsername = errors.New("password must not contain the username")
+	ErrPasswordContainsEmail    = errors.New("password must not contain the email")
+	ErrPasswordBreached         = errors.New("password has appeared in a data breach, choose another one")
+)
+
+// emails with shorter local parts only have the whole email denied,
+// denying "jo" would deny too many passwords
+const minDeniedEmailLocalPart = 3
+
+// PasswordPolicy is what new passwords must meet. Lengths are counted in
+// characters, a zero length is no limit.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// passwords known from breaches are rejected, nil skips the check
+	Breached BreachedPasswords
+}
+
+// DefaultPasswordPolicy asks for length over character classes, as NIST
+// SP 800-63B recommends.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength: 8,
+	MaxLength: 64,
+}
+
+// PasswordOwner is the user a password is for, it must not contain their details.
+type PasswordOwner struct {
+	Username string
+	Email    string
+}
+
+// PasswordPolicyError lists every rule a password breaks, so all of them
+// can be shown at once.
+type PasswordPolicyError struct {
+	Violations []error
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrPasswordPolicy, strings.Join(e.Messages(), "; "))
+}
+
+func (e *PasswordPolicyError) Unwrap() []error {
+	return append([]error{ErrPasswordPolicy}, e.Violations...)
+}
+
+// Messages returns a message for each violation.
+func (e *PasswordPolicyError) Messages() []string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Error()
+	}
+	return messages
+}
+
+// Check returns a *PasswordPolicyError if the password breaks any rule of the policy.
+func (p PasswordPolicy) Check(password string, owner PasswordOwner) error {
+	var violations []error
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		violations = append(violations, fmt.Errorf("%w, it must be at least %d characters long", ErrPasswordTooShort, p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, fmt.Errorf("%w, it must be at most %d characters long", ErrPasswordTooLong, p.MaxLength))
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, ErrPasswordNoLower)
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, ErrPasswordNoUpper)
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, ErrPasswordNoDigit)
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, ErrPasswordNoSymbol)
+	}
+
+	folded := strings.ToLower(password)
+	if owner.Username != "" && strings.Contains(folded, strings.ToLower(owner.Username)) {
+		violations = append(violations, ErrPasswordContainsUsername)
+	}
+	if containsEmail(folded, strings.ToLower(owner.Email)) {
+		violations = append(violations, ErrPasswordContainsEmail)
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, ErrPasswordBreached)
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsEmail reports whether the folded password contains the email or its local part.
+func containsEmail(password, email string) bool {
+	if email == "" {
+		return false
+	}
+	if strings.Contains(password, email) {
+		return true
+	}
+	local, _, ok := strings.Cut(email, "@")
+	return ok && len(local) >= minDeniedEmailLocalPart && strings.Contains(password, local)
+}
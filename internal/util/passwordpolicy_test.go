@@ -0,0 +1,75 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	owner := PasswordOwner{Username: "alice", Email: "alice.w@example.com"}
+	strict := PasswordPolicy{
+		MinLength:     10,
+		MaxLength:     20,
+		RequireLower:  true,
+		RequireUpper:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+
+	testCases := []struct {
+		name       string
+		policy     PasswordPolicy
+		password   string
+		owner      PasswordOwner
+		violations []error
+	}{
+		{"OK", DefaultPasswordPolicy, "correct horse battery", owner, nil},
+		{"Multibyte", DefaultPasswordPolicy, "пароль-пароль", owner, nil},
+		{"TooShort", DefaultPasswordPolicy, "short", owner, []error{ErrPasswordTooShort}},
+		{"TooLong", DefaultPasswordPolicy, strings.Repeat("a", 65), owner, []error{ErrPasswordTooLong}},
+		{"ContainsUsername", DefaultPasswordPolicy, "my name is Alice!", owner, []error{ErrPasswordContainsUsername}},
+		{"ContainsEmail", DefaultPasswordPolicy, "Alice.W@Example.com", owner, []error{ErrPasswordContainsUsername, ErrPasswordContainsEmail}},
+		{"ContainsEmailLocalPart", DefaultPasswordPolicy, "xx-alice.w-xx", PasswordOwner{Email: owner.Email}, []error{ErrPasswordContainsEmail}},
+		{"ShortEmailLocalPart", DefaultPasswordPolicy, "jo jo jo jo", PasswordOwner{Email: "jo@example.com"}, nil},
+		{"NoOwner", DefaultPasswordPolicy, "alice.w@example.com", PasswordOwner{}, nil},
+		{"StrictOK", strict, "Tr0ub4dor&3x", owner, nil},
+		{"StrictAll", strict, "password", owner, []error{
+			ErrPasswordTooShort,
+			ErrPasswordNoUpper,
+			ErrPasswordNoDigit,
+			ErrPasswordNoSymbol,
+		}},
+		{"StrictNoLower", strict, "TR0UB4DOR&3X", owner, []error{ErrPasswordNoLower}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.Check(tc.password, tc.owner)
+			if tc.violations == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrPasswordPolicy)
+
+			var policyErr *PasswordPolicyError
+			assert.ErrorAs(t, err, &policyErr)
+			assert.Len(t, policyErr.Violations, len(tc.violations))
+			for _, violation := range tc.violations {
+				assert.ErrorIs(t, err, violation)
+			}
+			assert.Len(t, policyErr.Messages(), len(tc.violations))
+		})
+	}
+}
+
+func TestPasswordPolicyBreached(t *testing.T) {
+	list, err := ReadBreachedPasswordList(strings.NewReader("correct horse battery\n"))
+	assert.NoError(t, err)
+	policy := DefaultPasswordPolicy
+	policy.Breached = list
+
+	assert.ErrorIs(t, policy.Check("correct horse battery", PasswordOwner{}), ErrPasswordBreached)
+	assert.NoError(t, policy.Check("correct horse battery staple", PasswordOwner{}))
+}
@@ -0,0 +1,17 @@
+package util
+
+// Roles of users. Every user has exactly one of them.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// IsSupportedRole reports whether role is one of the known roles.
+func IsSupportedRole(role string) bool {
+	switch role {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package util
+
+import "slices"
+
+// Scopes limit what an access token can be used for, independently of the role.
+const (
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+	ScopeUsersAdmin = "users:admin"
+)
+
+// RoleScopes returns every scope a user with the role can be granted.
+func RoleScopes(role string) []string {
+	switch role {
+	case RoleUser, RoleModerator:
+		return []string{ScopeUsersRead, ScopeUsersWrite}
+	case RoleAdmin:
+		return []string{ScopeUsersRead, ScopeUsersWrite, ScopeUsersAdmin}
+	}
+	return nil
+}
+
+// FilterScopes returns the scopes a user with the role can be granted,
+// dropping the others.
+func FilterScopes(role string, scopes []string) []string {
+	allowed := RoleScopes(role)
+	filtered := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if slices.Contains(allowed, scope) && !slices.Contains(filtered, scope) {
+			filtered = append(filtered, scope)
+		}
+	}
+	return filtered
+}
+
+// AllowedScopes reports whether a user with the role can be granted all the scopes.
+func AllowedScopes(role string, scopes []string) bool {
+	allowed := RoleScopes(role)
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			return false
+		}
+	}
+	return true
+}
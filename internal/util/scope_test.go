@@ -0,0 +1,23 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterScopes(t *testing.T) {
+	requested := []string{ScopeUsersAdmin, ScopeUsersRead, "unknown", ScopeUsersRead}
+
+	assert.Equal(t, []string{ScopeUsersRead}, FilterScopes(RoleUser, requested))
+	assert.Equal(t, []string{ScopeUsersAdmin, ScopeUsersRead}, FilterScopes(RoleAdmin, requested))
+	assert.Empty(t, FilterScopes("unknown", requested))
+}
+
+func TestAllowedScopes(t *testing.T) {
+	assert.True(t, AllowedScopes(RoleUser, []string{ScopeUsersRead}))
+	assert.True(t, AllowedScopes(RoleUser, nil))
+	assert.False(t, AllowedScopes(RoleUser, []string{ScopeUsersRead, ScopeUsersAdmin}))
+	assert.True(t, AllowedScopes(RoleAdmin, []string{ScopeUsersRead, ScopeUsersAdmin}))
+	assert.False(t, AllowedScopes(RoleAdmin, []string{"users:delete"}))
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// NewSecureToken returns a url-safe random token built from n random bytes.
+func NewSecureToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex encoded sha256 of a token. Only these hashes
+// are stored, so a leaked table does not give working tokens. That holds
+// for tokens made by NewSecureToken only, short codes are found from their
+// sha256 by trying them all, hash those with HashCode.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashCode returns the hex encoded HMAC-SHA256 of a short code, such as
+// one made by NewOTP, under a server side key. Without the key a leaked
+// hash can not be matched against the few possible codes.
+func HashCode(key []byte, code string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewOTP returns a random one-time code of the given number of decimal digits.
+func NewOTP(digits int) (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate otp: %v", err)
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSecureToken(t *testing.T) {
+	token1, err := NewSecureToken(32)
+	assert.NoError(t, err)
+	assert.Len(t, token1, 43)
+
+	token2, err := NewSecureToken(32)
+	assert.NoError(t, err)
+	assert.NotEqual(t, token1, token2)
+	assert.Len(t, HashToken(token1), 64)
+	assert.NotEqual(t, HashToken(token1), HashToken(token2))
+}
+
+func TestHashCode(t *testing.T) {
+	key := []byte(RandomString(32))
+	code, err := NewOTP(6)
+	assert.NoError(t, err)
+
+	hash := HashCode(key, code)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, HashCode(key, code))
+	assert.NotEqual(t, HashToken(code), hash)
+	assert.NotEqual(t, hash, HashCode([]byte(RandomString(32)), code))
+}
+
+func TestNewOTP(t *testing.T) {
+	for range 100 {
+		otp, err := NewOTP(6)
+		assert.NoError(t, err)
+		assert.Regexp(t, `^[0-9]{6}$`, otp)
+	}
+}